@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	apiSocketFile = "apm.sock"
+	apiTokenFile  = "api-token"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Operation is a package operation started through the API. Its output is
+// streamed as "operation:progress" events.
+type Operation struct {
+	ID       string   `json:"id"`
+	Kind     string   `json:"kind"` // install or remove
+	Packages []string `json:"packages"`
+	Status   string   `json:"status"` // queued, running, done or failed
+	Error    string   `json:"error,omitempty"`
+	Started  int64    `json:"started,omitempty"`
+	Finished int64    `json:"finished,omitempty"`
+}
+
+// OperationProgress is emitted as an "operation:progress" event for every
+// status change and output line of an operation.
+type OperationProgress struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Line   string `json:"line,omitempty"`
+}
+
+// apiServer serves the App methods over HTTP on a Unix socket.
+type apiServer struct {
+	app   *App
+	token string
+
+	mu         sync.Mutex
+	operations map[string]*Operation
+	nextID     int
+	// opMu runs one operation at a time, as pacman holds a lock anyway
+	opMu sync.Mutex
+
+	subMu       sync.Mutex
+	subscribers map[chan sseEvent]struct{}
+}
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// apiSocketPath returns the socket path, $XDG_RUNTIME_DIR/apm.sock by default.
+func apiSocketPath() string {
+	if path := os.Getenv("APM_API_SOCKET"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, apiSocketFile)
+}
+
+// apiToken returns the token clients must send as a bearer token, creating it
+// on first use. It is stored readable only by the user.
+func apiToken() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, apiTokenFile)
+	if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("failed to save API token: %w", err)
+	}
+	return token, nil
+}
+
+// serveAPI serves the API until the App context is cancelled.
+func (a *App) serveAPI(socketPath string) error {
+	token, err := apiToken()
+	if err != nil {
+		return err
+	}
+
+	// Remove a socket left behind by a server that did not shut down cleanly
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is listening on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	// Create the socket accessible to the current user only; changing its
+	// mode afterwards would leave a window for other users to connect
+	umask := syscall.Umask(0o077)
+	listener, err := net.Listen("unix", socketPath)
+	syscall.Umask(umask)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+
+	s := &apiServer{
+		app:         a,
+		token:       token,
+		operations:  make(map[string]*Operation),
+		subscribers: make(map[chan sseEvent]struct{}),
+	}
+	a.events = s.publish
+
+	srv := &http.Server{
+		Handler:     s.routes(),
+		BaseContext: func(net.Listener) context.Context { return a.ctx },
+	}
+	go func() {
+		<-a.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	fmt.Printf("Listening on %s\n", socketPath)
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("API server failed: %w", err)
+	}
+	return nil
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", s.handleSpec)
+	mux.HandleFunc("/search", s.auth(s.handleSearch))
+	mux.HandleFunc("/installed", s.auth(s.handleInstalled))
+	mux.HandleFunc("/updates", s.auth(s.handleUpdates))
+	mux.HandleFunc("/install", s.auth(s.handleOperation("install")))
+	mux.HandleFunc("/remove", s.auth(s.handleOperation("remove")))
+	mux.HandleFunc("/operations/", s.auth(s.handleGetOperation))
+	mux.HandleFunc("/events", s.auth(s.handleEvents))
+	return mux
+}
+
+// auth rejects requests without the bearer token.
+func (s *apiServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			apiError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func apiJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
+
+func apiError(w http.ResponseWriter, status int, message string) {
+	apiJSON(w, status, map[string]string{"error": message})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return true
+}
+
+func (s *apiServer) handleSpec(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func (s *apiServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		apiError(w, http.StatusBadRequest, "missing query parameter q")
+		return
+	}
+	results := searchPackages(r.Context(), query, false)
+	if results == nil {
+		results = []PackageInfo{}
+	}
+	apiJSON(w, http.StatusOK, results)
+}
+
+func (s *apiServer) handleInstalled(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	q := r.URL.Query()
+	filter := InstalledFilter{
+		Explicit:   q.Has("explicit"),
+		Deps:       q.Has("deps"),
+		Foreign:    q.Has("foreign"),
+		Native:     q.Has("native"),
+		Repository: q.Get("repo"),
+	}
+	if err := filter.validate(); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	packages, err := s.app.GetInstalledPackagesFiltered(filter)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if packages == nil {
+		packages = []PackageInfo{}
+	}
+	apiJSON(w, http.StatusOK, packages)
+}
+
+func (s *apiServer) handleUpdates(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	report, err := s.app.CheckUpdates()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	apiJSON(w, http.StatusOK, report)
+}
+
+// handleOperation starts an install or remove operation in the background and
+// answers 202 with the operation, whose progress is sent on /events.
+func (s *apiServer) handleOperation(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			Packages []string `json:"packages"`
+			// Force removes packages that other installed packages require
+			Force bool `json:"force"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		if len(req.Packages) == 0 {
+			apiError(w, http.StatusBadRequest, "no packages given")
+			return
+		}
+		for _, name := range req.Packages {
+			if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t\n") {
+				apiError(w, http.StatusBadRequest, fmt.Sprintf("invalid package name %q", name))
+				return
+			}
+		}
+
+		// Removal skips dependency checks, so refuse to break other packages
+		// unless forced
+		if kind == "remove" && !req.Force {
+			checks, err := s.app.checkRemoval(req.Packages)
+			if err != nil {
+				apiError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			var broken []UninstallCheck
+			for _, check := range checks {
+				if len(check.RequiredBy) > 0 {
+					broken = append(broken, check)
+				}
+			}
+			if len(broken) > 0 {
+				apiJSON(w, http.StatusConflict, map[string]interface{}{
+					"error":  "removing would break other packages; set force to remove anyway",
+					"checks": broken,
+				})
+				return
+			}
+		}
+
+		s.mu.Lock()
+		s.nextID++
+		op := &Operation{ID: strconv.Itoa(s.nextID), Kind: kind, Packages: req.Packages, Status: "queued"}
+		s.operations[op.ID] = op
+		snapshot := *op
+		s.mu.Unlock()
+
+		go s.run(op)
+		apiJSON(w, http.StatusAccepted, snapshot)
+	}
+}
+
+func (s *apiServer) handleGetOperation(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/operations/")
+	s.mu.Lock()
+	op, ok := s.operations[id]
+	var snapshot Operation
+	if ok {
+		snapshot = *op
+	}
+	s.mu.Unlock()
+	if !ok {
+		apiError(w, http.StatusNotFound, "no such operation")
+		return
+	}
+	apiJSON(w, http.StatusOK, snapshot)
+}
+
+// handleEvents streams the App events as server-sent events until the client
+// disconnects.
+func (s *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apiError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	events := make(chan sseEvent, 64)
+	s.subMu.Lock()
+	s.subscribers[events] = struct{}{}
+	s.subMu.Unlock()
+	defer func() {
+		s.subMu.Lock()
+		delete(s.subscribers, events)
+		s.subMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data)
+		}
+		flusher.Flush()
+	}
+}
+
+// publish sends an App event to every /events subscriber. Slow subscribers
+// miss events rather than blocking the operation.
+func (s *apiServer) publish(name string, data ...interface{}) {
+	var payload interface{}
+	switch len(data) {
+	case 0:
+	case 1:
+		payload = data[0]
+	default:
+		payload = data
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding event %s: %v", name, err)
+		return
+	}
+
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for events := range s.subscribers {
+		select {
+		case events <- sseEvent{name: name, data: encoded}:
+		default:
+		}
+	}
+}
+
+// run executes an operation, streaming its output as events.
+func (s *apiServer) run(op *Operation) {
+	s.opMu.Lock()
+	defer s.opMu.Unlock()
+
+	s.setStatus(op, "running", "")
+	args := []string{"yay", "-S", "--noconfirm"}
+	if op.Kind == "remove" {
+		args = []string{"yay", "-Rdd", "--noconfirm"}
+	}
+	args = append(args, op.Packages...)
+
+	if err := s.app.runOperation(op.ID, "pkexec", args...); err != nil {
+		s.setStatus(op, "failed", err.Error())
+		return
+	}
+	s.setStatus(op, "done", "")
+}
+
+func (s *apiServer) setStatus(op *Operation, status, errMsg string) {
+	s.mu.Lock()
+	op.Status = status
+	op.Error = errMsg
+	switch status {
+	case "running":
+		op.Started = time.Now().Unix()
+	case "done", "failed":
+		op.Finished = time.Now().Unix()
+	}
+	s.mu.Unlock()
+	s.app.emit("operation:progress", OperationProgress{ID: op.ID, Status: status, Line: errMsg})
+}
+
+// runOperation runs a command and emits every line of its output as an
+// "operation:progress" event.
+func (a *App) runOperation(id, name string, args ...string) error {
+	cmd := exec.CommandContext(a.ctx, name, args...)
+	defer trackOperation(append([]string{name}, args...)...)()
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			a.emit("operation:progress", OperationProgress{ID: id, Status: "running", Line: scanner.Text()})
+		}
+		// Keep draining so the command never blocks on a full pipe
+		io.Copy(io.Discard, reader)
+	}()
+
+	err := cmd.Wait()
+	writer.Close()
+	<-done
+	if err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
+
+func cliServe(a *App, args []string, jsonOut bool, out io.Writer) error {
+	socketPath := apiSocketPath()
+	if len(args) > 0 {
+		socketPath = args[0]
+	}
+	return a.serveAPI(socketPath)
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
@@ -15,7 +17,7 @@ import (
 	"os/exec"
 
 	"github.com/Jguer/go-alpm/v2"
-	paconf "github.com/Morganamilo/go-pacmanconf"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 var h *alpm.Handle
@@ -23,11 +25,38 @@ var h *alpm.Handle
 // var dbs []alpm.IDB
 var dbs []alpm.IDB
 
+// handleMu guards h and dbs. reloadSyncDBs swaps in a new handle, so readers
+// hold the read lock for as long as they use the databases.
+var handleMu sync.RWMutex
+
 var DesktopEnv string
 
 // App struct
 type App struct {
 	ctx context.Context
+
+	// sessionIgnored holds packages the user chose to skip until restart
+	sessionIgnored map[string]bool
+	ignoreMu       sync.Mutex
+
+	// updates caches the result of the last update check, see CheckUpdates.
+	// localAt is the modification time of the local database it matches.
+	updates   []UpdateInfo
+	updatesAt time.Time
+	localAt   time.Time
+	updatesMu sync.Mutex
+
+	// tray is the tray icon while resident mode is active
+	tray        *trayIcon
+	updateCount int
+	quitting    bool
+	stateMu     sync.Mutex
+
+	// headless is set when running without Wails, see NewHeadlessApp
+	headless bool
+	// events receives the events of a headless App, e.g. to stream them
+	// over the API
+	events func(name string, data ...interface{})
 }
 
 // NewApp creates a new App application struct
@@ -40,13 +69,39 @@ func (a *App) startup(ctx context.Context) {
 	// Perform your setup here
 	a.ctx = ctx
 
+	if err := initBackend(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	go pruneAURCache()
+	go refreshAURMeta()
+	go a.runUpdateChecker()
+	a.applyTraySetting(a.GetSettings())
+}
+
+// initBackend opens the ALPM handle and loads the search index. It is shared
+// by the GUI and the headless modes.
+func initBackend() error {
 	DesktopEnv = getDesktopEnvironment()
 
+	if err := openGlobalHandle(); err != nil {
+		return err
+	}
+
+	loadIndex()
+	return nil
+}
+
+// openGlobalHandle initializes h and registers the repositories in dbs.
+func openGlobalHandle() error {
+	handleMu.Lock()
+	defer handleMu.Unlock()
+
 	var err error
 	h, err = alpm.Initialize("/", "/var/lib/pacman")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to initialize alpm: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to initialize alpm: %v", err)
 	}
 
 	// Register and sync repositories
@@ -54,15 +109,36 @@ func (a *App) startup(ctx context.Context) {
 	for _, repo := range repos {
 		db, err := h.RegisterSyncDB(repo, 0)
 		if err != nil {
-			fmt.Printf("Error getting sync db for %s: %v\n", repo, err)
-			return
+			log.Printf("Error getting sync db for %s: %v", repo, err)
+			return nil
 		}
 		dbs = append(dbs, db)
 	}
+	return nil
+}
+
+// releaseHandle releases the global handle at shutdown.
+func releaseHandle() {
+	handleMu.Lock()
+	defer handleMu.Unlock()
+	if h != nil {
+		h.Release()
+		h, dbs = nil, nil
+	}
+}
+
+// NewHeadlessApp creates an App that runs without a window, for the command
+// line and API modes. Events are not sent anywhere.
+func NewHeadlessApp(ctx context.Context) (*App, error) {
+	a := &App{ctx: ctx, headless: true}
+	if err := initBackend(); err != nil {
+		return nil, err
+	}
+	return a, nil
 }
 
 // domReady is called after front-end resources have been loaded
-func (a App) domReady(ctx context.Context) {
+func (a *App) domReady(ctx context.Context) {
 	// Add your action here
 }
 
@@ -70,15 +146,33 @@ func (a App) domReady(ctx context.Context) {
 // either by clicking the window close button or calling runtime.Quit.
 // Returning true will cause the application to continue, false will continue shutdown as normal.
 func (a *App) beforeClose(ctx context.Context) (prevent bool) {
+	// In resident mode the window only hides and the app keeps running in the tray
+	if a.closeToTray() {
+		runtime.WindowHide(ctx)
+		return true
+	}
 	return false
 }
 
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
 	// Perform your teardown here
-	if h != nil {
-		h.Release()
+	a.stopTray()
+	releaseHandle()
+}
+
+// emit sends an event to the frontend.
+func (a *App) emit(name string, data ...interface{}) {
+	if a.headless {
+		if a.events != nil {
+			a.events(name, data...)
+		}
+		return
 	}
+	if a.ctx == nil {
+		return
+	}
+	runtime.EventsEmit(a.ctx, name, data...)
 }
 
 type PackageInfo struct {
@@ -90,9 +184,30 @@ type PackageInfo struct {
 	UpstreamURL string   `json:"upstreamurl"`
 	DependList  []string `json:"dependlist"`
 	LastUpdated string   `json:"lastupdated"`
+	// Stale is set when an AUR result was served from the offline cache.
+	Stale bool `json:"stale,omitempty"`
+	// CacheAge is the age of a cached AUR result in seconds.
+	CacheAge int64 `json:"cacheAge,omitempty"`
+	// InstallReason is "explicit" or "dependency" for installed packages.
+	InstallReason string `json:"installReason,omitempty"`
+	// InstallDate is the Unix time the installed package was installed.
+	InstallDate   int64 `json:"installDate,omitempty"`
+	InstalledSize int64 `json:"installedSize,omitempty"`
+	// RequiredBy and OptionalFor list the packages that depend on this one.
+	// They are only filled in by GetMultiplePackageInfo.
+	RequiredBy  []string `json:"requiredBy,omitempty"`
+	OptionalFor []string `json:"optionalFor,omitempty"`
 }
 
 func (a *App) SearchPackage(query string) []PackageInfo {
+	return searchPackages(a.ctx, query, true)
+}
+
+// searchPackages searches the sync repositories and the AUR. With debounce set
+// the search is interactive: the AUR query is dropped when another debounced
+// search starts shortly after, as happens while the user is typing, and it
+// does not wait longer than aurSearchWait for the rate limit.
+func searchPackages(ctx context.Context, query string, debounce bool) []PackageInfo {
 	var results []PackageInfo
 	var wg sync.WaitGroup
 	resultChan := make(chan PackageInfo, 100)
@@ -106,20 +221,54 @@ func (a *App) SearchPackage(query string) []PackageInfo {
 		doneChan <- true
 	}()
 
-	// Search official repositories concurrently
-	for _, db := range dbs {
+	// Search official repositories through the index when it is available,
+	// walking the databases concurrently otherwise
+	idx := currentIndex()
+	if idx != nil {
+		for _, pkg := range idx.search(query) {
+			resultChan <- pkg
+		}
+	} else {
 		wg.Add(1)
-		go func(db alpm.IDB) {
+		go func() {
 			defer wg.Done()
-			searchDB(db, query, resultChan)
-		}(db)
+			handleMu.RLock()
+			defer handleMu.RUnlock()
+
+			var dbWg sync.WaitGroup
+			for _, db := range dbs {
+				dbWg.Add(1)
+				go func(db alpm.IDB) {
+					defer dbWg.Done()
+					searchDB(db, query, resultChan)
+				}(db)
+			}
+			dbWg.Wait()
+		}()
 	}
 
 	// Search AUR concurrently
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		searchAUR(query, resultChan)
+		if len(query) < aurMinQueryLen {
+			return
+		}
+		ctx := ctx
+		if debounce {
+			// Cached answers need no debouncing as they cost no request
+			if !aurCached(aurSearchURL(query)) && !debounceAURSearch() {
+				return
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, aurSearchWait)
+			defer cancel()
+		}
+		if !searchAUR(ctx, query, resultChan) && idx != nil {
+			for _, name := range idx.searchAURNames(query) {
+				resultChan <- PackageInfo{Name: name, Repository: "AUR"}
+			}
+		}
 	}()
 
 	// Wait for all searches to complete
@@ -135,28 +284,32 @@ func (a *App) SearchPackage(query string) []PackageInfo {
 func searchDB(db alpm.IDB, query string, resultChan chan<- PackageInfo) {
 	db.PkgCache().ForEach(func(pkg alpm.IPackage) error {
 		if strings.Contains(strings.ToLower(pkg.Name()), strings.ToLower(query)) {
-			lastUpdated := pkg.BuildDate().UTC().Format("Jan. 2, 2006, 3 p.m. MST")
-			resultChan <- PackageInfo{
-				Name:        pkg.Name(),
-				Version:     pkg.Version(),
-				Description: pkg.Description(),
-				Repository:  db.Name(),
-				Maintainer:  pkg.Packager(),
-				UpstreamURL: pkg.URL(),
-				DependList:  convertDependList(pkg.Depends()),
-				LastUpdated: lastUpdated,
-			}
+			resultChan <- syncPackageInfo(db.Name(), pkg)
 		}
 		return nil
 	})
 }
 
-func searchAUR(query string, resultChan chan<- PackageInfo) {
-	cmd := exec.Command("curl", "-s", fmt.Sprintf("https://aur.archlinux.org/rpc/?v=5&type=search&arg=%s", query))
-	output, err := cmd.Output()
+func syncPackageInfo(repo string, pkg alpm.IPackage) PackageInfo {
+	return PackageInfo{
+		Name:        pkg.Name(),
+		Version:     pkg.Version(),
+		Description: pkg.Description(),
+		Repository:  repo,
+		Maintainer:  pkg.Packager(),
+		UpstreamURL: pkg.URL(),
+		DependList:  convertDependList(pkg.Depends()),
+		LastUpdated: pkg.BuildDate().UTC().Format("Jan. 2, 2006, 3 p.m. MST"),
+	}
+}
+
+// searchAUR queries the AUR RPC, falling back to the cached AUR metadata when
+// offline or rate limited, and reports whether it got an answer.
+func searchAUR(ctx context.Context, query string, resultChan chan<- PackageInfo) bool {
+	output, age, stale, err := aurRPC(ctx, aurSearchURL(query))
 	if err != nil {
-		fmt.Printf("Error searching AUR: %v\n", err)
-		return
+		log.Printf("Error searching AUR: %v", err)
+		return searchAURMeta(query, resultChan)
 	}
 
 	var aurResponse struct {
@@ -171,8 +324,8 @@ func searchAUR(query string, resultChan chan<- PackageInfo) {
 	}
 	err = json.Unmarshal(output, &aurResponse)
 	if err != nil {
-		fmt.Printf("Error parsing AUR response: %v\n", err)
-		return
+		log.Printf("Error parsing AUR response: %v", err)
+		return false
 	}
 
 	for _, aurPkg := range aurResponse.Results {
@@ -186,8 +339,11 @@ func searchAUR(query string, resultChan chan<- PackageInfo) {
 			UpstreamURL: aurPkg.URL,
 			DependList:  nil,
 			LastUpdated: lastUpdated,
+			Stale:       stale,
+			CacheAge:    int64(age.Seconds()),
 		}
 	}
+	return true
 }
 
 func convertDependList(depList alpm.IDependList) []string {
@@ -199,44 +355,12 @@ func convertDependList(depList alpm.IDependList) []string {
 	return deps
 }
 
+// GetInstalledPackages returns all installed packages with their install
+// reason and origin repository.
 func (a *App) GetInstalledPackages() ([]PackageInfo, error) {
-	h, err := alpm.Initialize("/", "/var/lib/pacman")
+	packages, err := a.GetInstalledPackagesFiltered(InstalledFilter{})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to initialize alpm: %v\n", err)
-		os.Exit(1)
-	}
-
-	if h == nil {
-		return nil, fmt.Errorf("ALPM handle is not initialized")
-	}
-
-	db, err := h.LocalDB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get local DB: %v", err)
-	}
-
-	var packages []PackageInfo
-	var mutex sync.Mutex
-
-	err = db.PkgCache().ForEach(func(pkg alpm.IPackage) error {
-		mutex.Lock()
-		lastUpdated := pkg.BuildDate().UTC().Format("Jan. 2, 2006, 3 p.m. MST")
-		packages = append(packages, PackageInfo{
-			Name:        pkg.Name(),
-			Version:     pkg.Version(),
-			Description: pkg.Description(),
-			Repository:  pkg.DB().Name(),
-			Maintainer:  pkg.Packager(),
-			UpstreamURL: pkg.URL(),
-			DependList:  convertDependList(pkg.Depends()),
-			LastUpdated: lastUpdated,
-		})
-		mutex.Unlock()
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("error iterating over packages: %v", err)
+		return nil, err
 	}
 
 	if len(packages) == 0 {
@@ -260,8 +384,6 @@ func (a *App) SearchLocalPackage(pkg string) (bool, error) {
 		return false, nil
 	}
 
-	fmt.Println(strings.Contains(strings.ToLower(local.Name()), strings.ToLower(pkg)))
-
 	return strings.Contains(strings.ToLower(local.Name()), strings.ToLower(pkg)), nil
 }
 
@@ -285,60 +407,58 @@ func searchLocalDB(pkg string) (alpm.IPackage, error) {
 }
 
 func (a *App) CheckPackageInstalled(packageName string) bool {
-	h, err := alpm.Initialize("/", "/var/lib/pacman")
+	installed, err := packageInstalled(packageName)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to initialize alpm: %v\n", err)
-		os.Exit(1)
+		log.Printf("Error checking whether %s is installed: %v", packageName, err)
 	}
+	return installed
+}
+
+// packageInstalled reports whether a package is installed according to the
+// global handle, which runPrivileged reloads after every change.
+func packageInstalled(name string) (bool, error) {
+	handleMu.RLock()
+	defer handleMu.RUnlock()
 	if h == nil {
-		log.Fatal("ALPM handle is not initialized")
+		return false, fmt.Errorf("ALPM handle is not initialized")
 	}
 	localDB, err := h.LocalDB()
 	if err != nil {
-		log.Fatal(err)
+		return false, fmt.Errorf("failed to get local DB: %w", err)
 	}
-	packageHandle := localDB.Pkg(packageName)
-	return packageHandle != nil
+	return localDB.Pkg(name) != nil, nil
 }
 
-func (a *App) Install(pkg string) {
-	cmdStr := fmt.Sprintf("pkexec yay -S %s --noconfirm", pkg)
-	cmd := exec.Command("sh", "-c", cmdStr)
-	fmt.Println("Executing command:", cmdStr)
+// runPrivileged runs a command through pkexec. The error wraps the
+// *exec.ExitError of a failed command.
+func runPrivileged(args ...string) error {
+	cmd := exec.Command("pkexec", args...)
+	defer trackOperation(args...)()
 
-	var outBuffer, errBuffer bytes.Buffer
-	cmd.Stdout = &outBuffer
+	var errBuffer bytes.Buffer
 	cmd.Stderr = &errBuffer
 
 	err := cmd.Run()
+	// The handle caches the local database, which the command changed
+	reloadSyncDBs()
 	if err != nil {
-		fmt.Println("Error executing command:", err)
-		fmt.Println("stderr:", errBuffer.String())
-		return
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(errBuffer.String()))
 	}
-
-	fmt.Println("stdout:", outBuffer.String())
-	fmt.Println("stderr:", errBuffer.String())
+	return nil
 }
 
-func (a *App) Uninstall(pkg string) {
-	cmdStr := fmt.Sprintf("pkexec yay -Rdd %s --noconfirm", pkg)
-	cmd := exec.Command("sh", "-c", cmdStr)
-	fmt.Println("Executing command:", cmdStr)
-
-	var outBuffer, errBuffer bytes.Buffer
-	cmd.Stdout = &outBuffer
-	cmd.Stderr = &errBuffer
-
-	err := cmd.Run()
-	if err != nil {
-		fmt.Println("Error executing command:", err)
-		fmt.Println("stderr:", errBuffer.String())
-		return
+func (a *App) Install(pkg string) error {
+	if err := runPrivileged("yay", "-S", pkg, "--noconfirm"); err != nil {
+		return fmt.Errorf("failed to install %s: %w", pkg, err)
 	}
+	return nil
+}
 
-	fmt.Println("stdout:", outBuffer.String())
-	fmt.Println("stderr:", errBuffer.String())
+func (a *App) Uninstall(pkg string) error {
+	if err := runPrivileged("yay", "-Rdd", pkg, "--noconfirm"); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", pkg, err)
+	}
+	return nil
 }
 
 // func openTerminal(cmd string) {
@@ -377,18 +497,25 @@ func (a *App) GetMultiplePackageInfo(packageNames []string) ([]PackageInfo, erro
 	resultChan := make(chan PackageInfo, len(packageNames))
 	errorChan := make(chan error, len(packageNames))
 
+	// Names of the packages found, by the name searched for
+	var resolvedMu sync.Mutex
+	resolved := make(map[string]string)
+
 	for _, pkgName := range packageNames {
 		wg.Add(1)
 		go func(name string) {
 			defer wg.Done()
 
 			// Search for package info
-			searchResults := a.SearchPackage(name)
+			searchResults := searchPackages(a.ctx, name, false)
 
 			var pkg PackageInfo
 			for _, result := range searchResults {
 				if result.Repository == "core" || result.Repository == "extra" || result.Repository == "AUR" {
 					pkg = result
+					resolvedMu.Lock()
+					resolved[name] = result.Name
+					resolvedMu.Unlock()
 					pkg.Name = name // Ensure the name matches the search query
 					resultChan <- pkg
 					return
@@ -432,9 +559,108 @@ func (a *App) GetMultiplePackageInfo(packageNames []string) ([]PackageInfo, erro
 		return iIndex < jIndex
 	})
 
+	names := make([]string, len(results))
+	for i, result := range results {
+		names[i] = resolved[result.Name]
+	}
+	if err := addReverseDeps(results, names); err != nil {
+		log.Printf("Error computing reverse dependencies: %v", err)
+	}
+
 	return results, nil
 }
 
+// GetPackageInfo returns the package with exactly the given name, looked up
+// in the local database, then the sync databases, then the AUR.
+func (a *App) GetPackageInfo(name string) (*PackageInfo, error) {
+	h, syncDBs, err := openSystemDB()
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+
+	var info *PackageInfo
+	if pkg := localDB.Pkg(name); pkg != nil {
+		installed := installedPackageInfo(pkg, originRepo(name, syncDBs, indexAURNames()))
+		info = &installed
+	} else {
+		for _, db := range syncDBs {
+			if pkg := db.Pkg(name); pkg != nil {
+				found := syncPackageInfo(db.Name(), pkg)
+				info = &found
+				break
+			}
+		}
+	}
+	if info == nil {
+		if info, err = a.aurPackageInfo(name); err != nil {
+			return nil, err
+		}
+	}
+
+	packages := []PackageInfo{*info}
+	if err := addReverseDeps(packages, []string{name}); err != nil {
+		log.Printf("Error computing reverse dependencies: %v", err)
+	}
+	return &packages[0], nil
+}
+
+// aurPackageInfo queries the AUR RPC for the package with exactly the given
+// name.
+func (a *App) aurPackageInfo(name string) (*PackageInfo, error) {
+	query := url.Values{"arg[]": {name}}
+	output, age, stale, err := aurRPC(a.ctx, "https://aur.archlinux.org/rpc/v5/info?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the AUR for %s: %w", name, err)
+	}
+
+	var aurResponse struct {
+		Results []struct {
+			Name         string   `json:"Name"`
+			Version      string   `json:"Version"`
+			Description  string   `json:"Description"`
+			Maintainer   string   `json:"Maintainer"`
+			URL          string   `json:"URL"`
+			Depends      []string `json:"Depends"`
+			LastModified int64    `json:"LastModified"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(output, &aurResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse AUR response: %v", err)
+	}
+	for _, aurPkg := range aurResponse.Results {
+		if aurPkg.Name != name {
+			continue
+		}
+		var deps []string
+		for _, dep := range aurPkg.Depends {
+			// Like convertDependList, keep only the name of "foo>=1.0"
+			if i := strings.IndexAny(dep, "<>="); i >= 0 {
+				dep = dep[:i]
+			}
+			deps = append(deps, dep)
+		}
+		return &PackageInfo{
+			Name:        aurPkg.Name,
+			Version:     aurPkg.Version,
+			Description: aurPkg.Description,
+			Repository:  "AUR",
+			Maintainer:  aurPkg.Maintainer,
+			UpstreamURL: aurPkg.URL,
+			DependList:  deps,
+			LastUpdated: time.Unix(aurPkg.LastModified, 0).UTC().Format("02-01-2006"),
+			Stale:       stale,
+			CacheAge:    int64(age.Seconds()),
+		}, nil
+	}
+	return nil, fmt.Errorf("package %s not found", name)
+}
+
 func indexOf(slice []string, item string) int {
 	for i, s := range slice {
 		if s == item {
@@ -450,31 +676,134 @@ type UpdateInfo struct {
 	NewVersion   string `json:"newVersion"`
 	Repository   string `json:"repository"`
 	DownloadSize int64  `json:"downloadSize"`
+	// InstalledSize is the installed size of the new version and SizeChange
+	// the difference to the installed version. SizeUnknown is set for AUR
+	// packages whose sizes are only known once they are built.
+	InstalledSize int64 `json:"installedSize"`
+	SizeChange    int64 `json:"sizeChange"`
+	SizeUnknown   bool  `json:"sizeUnknown,omitempty"`
+	// Ignored is set for updates skipped by IgnorePkg, IgnoreGroup or the
+	// session ignore list; IgnoreReason tells which one.
+	Ignored      bool   `json:"ignored"`
+	IgnoreReason string `json:"ignoreReason,omitempty"`
+	// Held is set for packages listed in HoldPkg.
+	Held bool `json:"held"`
+	// Kind is "upgrade", "replace", "conflict" or "devel". Replaces lists the
+	// installed packages a replacement supersedes and Conflicts the installed
+	// packages the new version conflicts with.
+	Kind      string   `json:"kind"`
+	Replaces  []string `json:"replaces,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 // GetAvailableUpdates returns a list of available updates for packages
 func (a *App) GetAvailableUpdates() ([]UpdateInfo, error) {
-	// Initialize ALPM
-	h, err := alpm.Initialize("/", "/var/lib/pacman")
+	report, err := a.CheckUpdates()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+		return nil, err
 	}
-	defer h.Release()
+	return report.Updates, nil
+}
+
+// CheckUpdates returns the available updates together with a summary of
+// their download and installed sizes. The databases are only synchronized
+// again once updateCheckInterval has passed since the last check, so the
+// result can be polled; RefreshDatabases(true) forces a new check.
+func (a *App) CheckUpdates() (*UpdateReport, error) {
+	return a.checkUpdates(false)
+}
 
-	// Parse pacman configuration
-	pacmanConfig, _, err := paconf.ParseFile("/etc/pacman.conf")
+// checkUpdates returns the cached updates, or checks for updates when force
+// is set or the cache is too old.
+func (a *App) checkUpdates(force bool) (*UpdateReport, error) {
+	a.updatesMu.Lock()
+	localAt := localDBModTime()
+	if force || a.updatesAt.IsZero() || time.Since(a.updatesAt) >= updateCheckInterval {
+		updates, err := a.findUpdates()
+		if err != nil {
+			a.updatesMu.Unlock()
+			return nil, err
+		}
+		a.updates, a.updatesAt = updates, time.Now()
+	} else if !localAt.Equal(a.localAt) {
+		a.pruneUpdates()
+	}
+	a.localAt = localAt
+	updates := append([]UpdateInfo(nil), a.updates...)
+	a.updatesMu.Unlock()
+
+	// The session ignore list may have changed since the check
+	session := a.GetSessionIgnored()
+	for i := range updates {
+		if update := &updates[i]; update.IgnoreReason == "" || update.IgnoreReason == "session" {
+			update.Ignored = contains(session, update.Name)
+			update.IgnoreReason = ""
+			if update.Ignored {
+				update.IgnoreReason = "session"
+			}
+		}
+	}
+
+	news, err := a.GetUnreadNews()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse pacman config: %v", err)
+		log.Printf("Error checking Arch news: %v", err)
 	}
 
-	// Register sync databases
-	for _, repo := range pacmanConfig.Repos {
-		db, err := h.RegisterSyncDB(repo.Name, 0)
-		if err != nil {
-			return nil, fmt.Errorf("failed to register sync db %s: %v", repo.Name, err)
+	summary := summarizeUpdates(updates)
+	a.setUpdateCount(summary.Count)
+
+	return &UpdateReport{Updates: updates, Summary: summary, News: news}, nil
+}
+
+// localDBModTime returns the modification time of the local database
+// directory, which changes whenever packages are installed, upgraded or
+// removed.
+func localDBModTime() time.Time {
+	fi, err := os.Stat(filepath.Join(pacmanDBPath, "local"))
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// pruneUpdates drops the cached updates of packages whose installed version
+// changed since the check, e.g. because they were just upgraded. The caller
+// must hold updatesMu.
+func (a *App) pruneUpdates() {
+	handle, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		log.Printf("Error initializing alpm: %v", err)
+		return
+	}
+	defer handle.Release()
+	localDB, err := handle.LocalDB()
+	if err != nil {
+		log.Printf("Error getting local DB: %v", err)
+		return
+	}
+
+	pending := a.updates[:0]
+	for _, update := range a.updates {
+		name := update.Name
+		if update.Kind == updateKindReplace {
+			// Replacements are pending while the replaced package is installed
+			name = update.Replaces[0]
+		}
+		if pkg := localDB.Pkg(name); pkg != nil && pkg.Version() == update.OldVersion {
+			pending = append(pending, update)
 		}
-		db.SetServers(repo.Servers)
 	}
+	a.updates = pending
+}
+
+// findUpdates synchronizes a private copy of the databases and compares it
+// with the installed packages.
+func (a *App) findUpdates() ([]UpdateInfo, error) {
+	h, pacmanConfig, release, err := a.openCheckDB()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	// Get local database
 	localDB, err := h.LocalDB()
@@ -488,6 +817,8 @@ func (a *App) GetAvailableUpdates() ([]UpdateInfo, error) {
 		return nil, fmt.Errorf("failed to get sync DBs: %v", err)
 	}
 
+	filter := a.newUpdateFilter(pacmanConfig)
+
 	var updates []UpdateInfo
 	var mutex sync.Mutex
 	var wg sync.WaitGroup
@@ -503,18 +834,33 @@ func (a *App) GetAvailableUpdates() ([]UpdateInfo, error) {
 			default:
 				newPkg := pkg.SyncNewVersion(syncDBs)
 				if newPkg != nil {
+					update := UpdateInfo{
+						Name:          pkg.Name(),
+						OldVersion:    pkg.Version(),
+						NewVersion:    newPkg.Version(),
+						Repository:    newPkg.DB().Name(),
+						DownloadSize:  downloadSize(newPkg, pacmanConfig.CacheDir),
+						InstalledSize: newPkg.ISize(),
+						SizeChange:    newPkg.ISize() - pkg.ISize(),
+						Kind:          updateKindUpgrade,
+					}
+					if conflicts := findConflicts(localDB, newPkg, pkg.Name()); len(conflicts) > 0 {
+						update.Kind = updateKindConflict
+						update.Conflicts = conflicts
+					}
+					filter.apply(&update, newPkg.Groups().Slice())
 					mutex.Lock()
-					updates = append(updates, UpdateInfo{
-						Name:         pkg.Name(),
-						OldVersion:   pkg.Version(),
-						NewVersion:   newPkg.Version(),
-						Repository:   newPkg.DB().Name(),
-						DownloadSize: newPkg.Size(),
-					})
+					updates = append(updates, update)
 					mutex.Unlock()
 				}
 			}
 		}
+
+		// Packages renamed or superseded through replaces= in the repositories
+		replacements := findReplacements(localDB, syncDBs, filter, pacmanConfig.CacheDir)
+		mutex.Lock()
+		updates = append(updates, replacements...)
+		mutex.Unlock()
 	}()
 
 	// Check for AUR updates
@@ -526,13 +872,15 @@ func (a *App) GetAvailableUpdates() ([]UpdateInfo, error) {
 			log.Printf("Error checking AUR updates: %v", err)
 			return
 		}
+		for i := range aurUpdates {
+			filter.apply(&aurUpdates[i], nil)
+		}
 		mutex.Lock()
 		updates = append(updates, aurUpdates...)
 		mutex.Unlock()
 	}()
 
 	wg.Wait()
-
 	return updates, nil
 }
 
@@ -546,50 +894,78 @@ func (a *App) checkAURUpdates() ([]UpdateInfo, error) {
 		return nil, fmt.Errorf("failed to get AUR package list: %v", err)
 	}
 
-	aurPackages := strings.Split(strings.TrimSpace(string(output)), "\n")
+	installed := make(map[string]string)
+	bases := make(map[string]string)
+	var names []string
+	for _, pkg := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Fields(pkg)
+		if len(parts) != 2 {
+			continue
+		}
+		installed[parts[0]] = parts[1]
+		names = append(names, parts[0])
+	}
 
-	for _, pkg := range aurPackages {
+	// Query the AUR RPC in batches instead of once per package
+	for start := 0; start < len(names); start += aurInfoBatch {
 		select {
 		case <-a.ctx.Done():
 			return aurUpdates, a.ctx.Err()
 		default:
-			parts := strings.Fields(pkg)
-			if len(parts) != 2 {
-				continue
-			}
-			name, version := parts[0], parts[1]
-
-			// Check for updates using AUR RPC
-			cmd := exec.CommandContext(a.ctx, "curl", "-s", fmt.Sprintf("https://aur.archlinux.org/rpc/v5/info/%s", name))
-			output, err := cmd.Output()
-			if err != nil {
-				log.Printf("Error checking AUR for package %s: %v", name, err)
-				continue
-			}
+		}
 
-			var aurResponse struct {
-				Results []struct {
-					Version string `json:"Version"`
-				} `json:"results"`
-			}
-			err = json.Unmarshal(output, &aurResponse)
-			if err != nil {
-				log.Printf("Error parsing AUR response for package %s: %v", name, err)
-				continue
-			}
+		end := start + aurInfoBatch
+		if end > len(names) {
+			end = len(names)
+		}
+		query := url.Values{"arg[]": names[start:end]}
+		output, _, _, err := aurRPC(a.ctx, "https://aur.archlinux.org/rpc/v5/info?"+query.Encode())
+		if err != nil {
+			log.Printf("Error checking AUR for packages: %v", err)
+			continue
+		}
 
-			if len(aurResponse.Results) > 0 && aurResponse.Results[0].Version != version {
+		var aurResponse struct {
+			Results []struct {
+				Name        string `json:"Name"`
+				PackageBase string `json:"PackageBase"`
+				Version     string `json:"Version"`
+			} `json:"results"`
+		}
+		err = json.Unmarshal(output, &aurResponse)
+		if err != nil {
+			log.Printf("Error parsing AUR response: %v", err)
+			continue
+		}
+
+		for _, result := range aurResponse.Results {
+			bases[result.Name] = result.PackageBase
+			version, ok := installed[result.Name]
+			if ok && result.Version != version {
 				aurUpdates = append(aurUpdates, UpdateInfo{
-					Name:         name,
+					Name:         result.Name,
 					OldVersion:   version,
-					NewVersion:   aurResponse.Results[0].Version,
+					NewVersion:   result.Version,
 					Repository:   "AUR",
 					DownloadSize: 0,
+					SizeUnknown:  true,
+					Kind:         updateKindUpgrade,
 				})
 			}
 		}
 	}
 
+	// VCS packages only change version when rebuilt, so compare upstream revisions
+	upgraded := make(map[string]bool)
+	for _, update := range aurUpdates {
+		upgraded[update.Name] = true
+	}
+	for _, update := range a.checkDevelUpdates(installed, bases) {
+		if !upgraded[update.Name] {
+			aurUpdates = append(aurUpdates, update)
+		}
+	}
+
 	return aurUpdates, nil
 }
 
@@ -606,42 +982,30 @@ func (a *App) HumanReadableSize(size int64) string {
 	return fmt.Sprintf("%d%s", size, "B")
 }
 
-func (a *App) UpdateSinglePkg(pkg string) {
-	cmdStr := fmt.Sprintf("pkexec yay -S %s --noconfirm", pkg)
-	cmd := exec.Command("sh", "-c", cmdStr)
-	fmt.Println("Executing command:", cmdStr)
-
-	var outBuffer, errBuffer bytes.Buffer
-	cmd.Stdout = &outBuffer
-	cmd.Stderr = &errBuffer
-
-	err := cmd.Run()
-	if err != nil {
-		fmt.Println("Error executing command:", err)
-		fmt.Println("stderr:", errBuffer.String())
-		return
+func (a *App) UpdateSinglePkg(pkg string) error {
+	if err := runPrivileged("yay", "-S", pkg, "--noconfirm"); err != nil {
+		return fmt.Errorf("failed to update %s: %w", pkg, err)
 	}
-
-	fmt.Println("stdout:", outBuffer.String())
-	fmt.Println("stderr:", errBuffer.String())
+	return nil
 }
 
-func (a *App) UpdateAllPkg() {
-	cmdStr := "pkexec yay -Syu --noconfirm"
-	cmd := exec.Command("sh", "-c", cmdStr)
-	fmt.Println("Executing command:", cmdStr)
-
-	var outBuffer, errBuffer bytes.Buffer
-	cmd.Stdout = &outBuffer
-	cmd.Stderr = &errBuffer
-
-	err := cmd.Run()
+// UpdateAllPkg upgrades the whole system. It fails while there is unread Arch
+// news, which must be acknowledged with AcknowledgeNews first.
+func (a *App) UpdateAllPkg() error {
+	news, err := a.GetUnreadNews()
 	if err != nil {
-		fmt.Println("Error executing command:", err)
-		fmt.Println("stderr:", errBuffer.String())
-		return
+		log.Printf("Error checking Arch news: %v", err)
+	}
+	if len(news) > 0 {
+		return fmt.Errorf("%d unread Arch news items must be acknowledged before upgrading", len(news))
 	}
 
-	fmt.Println("stdout:", outBuffer.String())
-	fmt.Println("stderr:", errBuffer.String())
+	args := []string{"yay", "-Syu", "--noconfirm"}
+	if ignored := a.GetSessionIgnored(); len(ignored) > 0 {
+		args = append(args, "--ignore", strings.Join(ignored, ","))
+	}
+	if err := runPrivileged(args...); err != nil {
+		return fmt.Errorf("failed to upgrade the system: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	aurCacheTTL      = 30 * time.Minute
+	aurCacheMaxStale = 7 * 24 * time.Hour
+	aurMetaURL       = "https://aur.archlinux.org/packages-meta-v1.json.gz"
+	aurMetaFile      = "packages-meta-v1.json.gz"
+	aurMetaTTL       = 24 * time.Hour
+)
+
+var aurClient = &http.Client{Timeout: 15 * time.Second}
+
+// aurMetaPackage is an entry of the AUR packages-meta dump.
+type aurMetaPackage struct {
+	Name         string `json:"Name"`
+	Version      string `json:"Version"`
+	Description  string `json:"Description"`
+	Maintainer   string `json:"Maintainer"`
+	URL          string `json:"URL"`
+	LastModified int64  `json:"LastModified"`
+}
+
+var (
+	aurMeta      []aurMetaPackage
+	aurMetaMtime time.Time
+	aurMetaMu    sync.Mutex
+)
+
+func aurCacheDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "aur")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create AUR cache dir: %w", err)
+	}
+	return dir, nil
+}
+
+// aurSearchURL returns the RPC URL searching the AUR for query.
+func aurSearchURL(query string) string {
+	return "https://aur.archlinux.org/rpc/?v=5&type=search&arg=" + url.QueryEscape(query)
+}
+
+// aurCached reports whether aurRPC can answer url from the cache without a
+// request.
+func aurCached(url string) bool {
+	dir, err := aurCacheDir()
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(url))
+	fi, err := os.Stat(filepath.Join(dir, hex.EncodeToString(sum[:])+".json"))
+	return err == nil && time.Since(fi.ModTime()) < aurCacheTTL
+}
+
+// aurRPC fetches url from the AUR RPC and answers from the on-disk cache while
+// the cached response is younger than aurCacheTTL. When the AUR cannot be
+// reached an expired response is served instead and stale is set. age is the
+// age of the returned response.
+func aurRPC(ctx context.Context, url string) (body []byte, age time.Duration, stale bool, err error) {
+	dir, err := aurCacheDir()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+
+	fi, statErr := os.Stat(path)
+	if statErr == nil && time.Since(fi.ModTime()) < aurCacheTTL {
+		if body, err := os.ReadFile(path); err == nil {
+			return body, time.Since(fi.ModTime()), false, nil
+		}
+	}
+
+	body, err = aurGet(ctx, url)
+	if err != nil {
+		if statErr == nil {
+			if cached, readErr := os.ReadFile(path); readErr == nil {
+				log.Printf("AUR unreachable, serving cached response: %v", err)
+				return cached, time.Since(fi.ModTime()), true, nil
+			}
+		}
+		return nil, 0, false, err
+	}
+
+	// Only cache well-formed RPC answers, not error pages.
+	if json.Valid(body) {
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			log.Printf("Error caching AUR response: %v", err)
+		}
+	}
+	return body, 0, false, nil
+}
+
+// pruneAURCache removes cached RPC responses too old to be served even when offline.
+func pruneAURCache() {
+	dir, err := aurCacheDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > aurCacheMaxStale {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// refreshAURMeta downloads the AUR packages-meta dump when the cached copy is
+// older than aurMetaTTL.
+func refreshAURMeta() {
+	dir, err := cacheDir()
+	if err != nil {
+		log.Printf("Error refreshing AUR metadata: %v", err)
+		return
+	}
+	path := filepath.Join(dir, aurMetaFile)
+	if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < aurMetaTTL {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aurMetaURL, nil)
+	if err != nil {
+		return
+	}
+	// Ask for the raw file so it is stored compressed.
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Error downloading AUR metadata: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Error downloading AUR metadata: unexpected status %s", resp.Status)
+		return
+	}
+
+	tmp, err := os.CreateTemp(dir, aurMetaFile+".*")
+	if err != nil {
+		log.Printf("Error saving AUR metadata: %v", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		log.Printf("Error saving AUR metadata: %v", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("Error saving AUR metadata: %v", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		log.Printf("Error saving AUR metadata: %v", err)
+	}
+}
+
+// loadAURMeta returns the cached packages-meta dump and its age, parsing it
+// again only when the file changed.
+func loadAURMeta() ([]aurMetaPackage, time.Duration, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, 0, err
+	}
+	path := filepath.Join(dir, aurMetaFile)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("no cached AUR metadata: %w", err)
+	}
+
+	aurMetaMu.Lock()
+	defer aurMetaMu.Unlock()
+	if aurMeta != nil && aurMetaMtime.Equal(fi.ModTime()) {
+		return aurMeta, time.Since(fi.ModTime()), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read AUR metadata: %w", err)
+	}
+	defer gz.Close()
+
+	var pkgs []aurMetaPackage
+	if err := json.NewDecoder(gz).Decode(&pkgs); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse AUR metadata: %w", err)
+	}
+	aurMeta, aurMetaMtime = pkgs, fi.ModTime()
+	return aurMeta, time.Since(fi.ModTime()), nil
+}
+
+// searchAURMeta answers an AUR search from the cached packages-meta dump.
+// Results are always marked stale.
+func searchAURMeta(query string, resultChan chan<- PackageInfo) bool {
+	pkgs, age, err := loadAURMeta()
+	if err != nil {
+		log.Printf("Error searching cached AUR metadata: %v", err)
+		return false
+	}
+
+	query = strings.ToLower(query)
+	for _, pkg := range pkgs {
+		if !strings.Contains(strings.ToLower(pkg.Name), query) &&
+			!strings.Contains(strings.ToLower(pkg.Description), query) {
+			continue
+		}
+		resultChan <- PackageInfo{
+			Name:        pkg.Name,
+			Version:     pkg.Version,
+			Description: pkg.Description,
+			Repository:  "AUR",
+			Maintainer:  pkg.Maintainer,
+			UpstreamURL: pkg.URL,
+			LastUpdated: time.Unix(pkg.LastModified, 0).UTC().Format("02-01-2006"),
+			Stale:       true,
+			CacheAge:    int64(age.Seconds()),
+		}
+	}
+	return true
+}
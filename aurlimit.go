@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// The AUR allows 4000 RPC requests per day and IP. One token every 24
+	// seconds sustains 3600 requests a day, leaving room for the burst.
+	aurBurst       = 20
+	aurRefill      = 24 * time.Second
+	aurMaxRetries  = 3
+	aurBaseBackoff = time.Second
+	aurMaxBackoff  = 5 * time.Minute
+
+	// aurMinQueryLen is the shortest search term accepted by the RPC.
+	aurMinQueryLen = 2
+	// aurInfoBatch is the number of packages looked up per info request.
+	aurInfoBatch = 100
+	// aurSearchDebounce is how long a search waits for the user to stop typing,
+	// and aurSearchWait how long it may then wait for the rate limit.
+	aurSearchDebounce = 300 * time.Millisecond
+	aurSearchWait     = 2 * time.Second
+)
+
+var errAURRateLimited = errors.New("AUR rate limit reached")
+
+// tokenBucket is a simple token bucket rate limiter.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	interval time.Duration
+	last     time.Time
+}
+
+func newTokenBucket(capacity int, interval time.Duration) *tokenBucket {
+	return &tokenBucket{
+		tokens:   float64(capacity),
+		capacity: float64(capacity),
+		interval: interval,
+		last:     time.Now(),
+	}
+}
+
+// take refills the bucket up to now and takes a token. Without a token it
+// returns how long until the next one is available.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.After(b.last) {
+		b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(b.interval))
+}
+
+// wait blocks until a token is available or ctx is done. It fails right away
+// with errAURRateLimited when the next token comes after the deadline of ctx.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		ok, delay := b.take(time.Now())
+		if ok {
+			return nil
+		}
+		if deadline, set := ctx.Deadline(); set && time.Until(deadline) < delay {
+			return errAURRateLimited
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// aurCall is an in-flight AUR request shared by identical callers.
+type aurCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+var (
+	aurBucket = newTokenBucket(aurBurst, aurRefill)
+
+	aurCalls   = map[string]*aurCall{}
+	aurCallsMu sync.Mutex
+
+	aurBlockedUntil time.Time
+	aurBlockedMu    sync.Mutex
+
+	// aurSearchGen is bumped for every AUR search so superseded keystrokes can bail out.
+	aurSearchGen atomic.Uint64
+)
+
+// aurGet performs a rate limited GET request against the AUR and returns the
+// response body. Identical concurrent requests share a single round trip and
+// HTTP 429 answers are retried with exponential backoff.
+func aurGet(ctx context.Context, url string) ([]byte, error) {
+	aurCallsMu.Lock()
+	if call, ok := aurCalls[url]; ok {
+		aurCallsMu.Unlock()
+		select {
+		case <-call.done:
+			return call.body, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &aurCall{done: make(chan struct{})}
+	aurCalls[url] = call
+	aurCallsMu.Unlock()
+
+	call.body, call.err = aurGetWithBackoff(ctx, url)
+
+	aurCallsMu.Lock()
+	delete(aurCalls, url)
+	aurCallsMu.Unlock()
+	close(call.done)
+
+	return call.body, call.err
+}
+
+func aurGetWithBackoff(ctx context.Context, url string) ([]byte, error) {
+	backoff := aurBaseBackoff
+	for attempt := 0; ; attempt++ {
+		if until := aurBlocked(); !until.IsZero() {
+			return nil, fmt.Errorf("%w until %s", errAURRateLimited, until.Format(time.Kitchen))
+		}
+		if err := aurBucket.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		body, retryAfter, err := aurDo(ctx, url)
+		if !errors.Is(err, errAURRateLimited) {
+			return body, err
+		}
+
+		delay, retry := aurBackoff(attempt, backoff, retryAfter)
+		if !retry {
+			blockAUR(delay)
+			return nil, err
+		}
+		log.Printf("AUR rate limited, retrying in %v", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff = delay * 2
+	}
+}
+
+// aurBackoff returns how long to wait after a rate limited attempt, given the
+// backoff so far and the delay the server asked for, and whether to retry.
+// Without a retry the AUR should be left alone for the returned delay.
+func aurBackoff(attempt int, backoff, retryAfter time.Duration) (time.Duration, bool) {
+	delay := backoff
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay, attempt < aurMaxRetries && delay <= aurMaxBackoff
+}
+
+// aurDo performs a single request. On HTTP 429 it returns errAURRateLimited
+// along with the delay requested by the server, if any.
+func aurDo(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := aurClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		return body, 0, err
+	case http.StatusTooManyRequests:
+		var retryAfter time.Duration
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return nil, retryAfter, errAURRateLimited
+	default:
+		return nil, 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// aurBlocked returns the time until which AUR requests are suspended, or the
+// zero time if requests are allowed.
+func aurBlocked() time.Time {
+	aurBlockedMu.Lock()
+	defer aurBlockedMu.Unlock()
+	if time.Now().After(aurBlockedUntil) {
+		return time.Time{}
+	}
+	return aurBlockedUntil
+}
+
+func blockAUR(d time.Duration) {
+	aurBlockedMu.Lock()
+	defer aurBlockedMu.Unlock()
+	aurBlockedUntil = time.Now().Add(d)
+}
+
+// debounceAURSearch waits for aurSearchDebounce and reports whether no newer
+// search was started in the meantime.
+func debounceAURSearch() bool {
+	gen := aurSearchGen.Add(1)
+	time.Sleep(aurSearchDebounce)
+	return aurSearchGen.Load() == gen
+}
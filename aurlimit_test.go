@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	b := newTokenBucket(2, 10*time.Second)
+	now := b.last
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.take(now); !ok {
+			t.Fatalf("take %d of the burst failed", i+1)
+		}
+	}
+	ok, delay := b.take(now)
+	if ok || delay != 10*time.Second {
+		t.Fatalf("take on an empty bucket = %v, %v; want false, 10s", ok, delay)
+	}
+
+	// Refills proportionally to the elapsed time
+	if ok, delay := b.take(now.Add(4 * time.Second)); ok || delay != 6*time.Second {
+		t.Errorf("take after 4s = %v, %v; want false, 6s", ok, delay)
+	}
+	if ok, _ := b.take(now.Add(10 * time.Second)); !ok {
+		t.Error("take after a full interval failed")
+	}
+
+	// Never holds more than its capacity
+	later := now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.take(later); !ok {
+			t.Fatalf("take %d after an hour failed", i+1)
+		}
+	}
+	if ok, _ := b.take(later); ok {
+		t.Error("bucket refilled beyond its capacity")
+	}
+}
+
+func TestTokenBucketWaitDeadline(t *testing.T) {
+	b := newTokenBucket(1, time.Minute)
+	b.take(time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := b.wait(ctx); !errors.Is(err, errAURRateLimited) {
+		t.Fatalf("wait = %v, want %v", err, errAURRateLimited)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("wait blocked for %v although no token could come in time", elapsed)
+	}
+}
+
+func TestAURBackoff(t *testing.T) {
+	tests := []struct {
+		attempt    int
+		backoff    time.Duration
+		retryAfter time.Duration
+		delay      time.Duration
+		retry      bool
+	}{
+		{0, time.Second, 0, time.Second, true},
+		{1, 2 * time.Second, 30 * time.Second, 30 * time.Second, true},
+		{2, 4 * time.Second, time.Second, 4 * time.Second, true},
+		{aurMaxRetries, 8 * time.Second, 0, 8 * time.Second, false},
+		{0, time.Second, time.Hour, time.Hour, false},
+	}
+	for _, tt := range tests {
+		delay, retry := aurBackoff(tt.attempt, tt.backoff, tt.retryAfter)
+		if delay != tt.delay || retry != tt.retry {
+			t.Errorf("aurBackoff(%d, %v, %v) = %v, %v; want %v, %v",
+				tt.attempt, tt.backoff, tt.retryAfter, delay, retry, tt.delay, tt.retry)
+		}
+	}
+}
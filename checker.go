@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// checkerTick is how often the background checker looks at its settings.
+const checkerTick = time.Minute
+
+// runUpdateChecker periodically checks for updates in the background until
+// the application shuts down. The interval is re-read from the settings on
+// every tick so changes apply without a restart.
+func (a *App) runUpdateChecker() {
+	var lastCheck time.Time
+	var lastSeen map[string]bool
+
+	ticker := time.NewTicker(checkerTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		settings := a.GetSettings()
+		if settings.CheckInterval == 0 || time.Since(lastCheck) < time.Duration(settings.CheckInterval)*time.Minute {
+			continue
+		}
+		if !settings.CheckOnBattery && onBattery() {
+			continue
+		}
+		if !settings.CheckOnMetered && onMeteredConnection() {
+			continue
+		}
+
+		lastCheck = time.Now()
+		report, err := a.checkUpdates(true)
+		if err != nil {
+			log.Printf("Background update check failed: %v", err)
+			continue
+		}
+
+		a.emit("updates:checked", report.Summary)
+
+		// Only notify when packages got updates since the last check
+		seen := pendingUpdates(report.Updates)
+		if settings.Notifications && hasNew(seen, lastSeen) {
+			a.notifyUpdates(report.Summary.Count)
+		}
+		lastSeen = seen
+	}
+}
+
+// pendingUpdates returns the names of the packages with updates that are not
+// ignored.
+func pendingUpdates(updates []UpdateInfo) map[string]bool {
+	names := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		if !update.Ignored {
+			names[update.Name] = true
+		}
+	}
+	return names
+}
+
+// hasNew reports whether current holds a name that previous does not.
+func hasNew(current, previous map[string]bool) bool {
+	for name := range current {
+		if !previous[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// onBattery reports whether the system is running on battery power.
+func onBattery() bool {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	battery := false
+	for _, supply := range supplies {
+		kind, _ := os.ReadFile(filepath.Join(supply, "type"))
+		switch strings.TrimSpace(string(kind)) {
+		case "Mains":
+			if online, _ := os.ReadFile(filepath.Join(supply, "online")); strings.TrimSpace(string(online)) == "1" {
+				return false
+			}
+		case "Battery":
+			battery = true
+		}
+	}
+	return battery
+}
+
+// onMeteredConnection asks NetworkManager whether the primary connection is
+// metered. Without NetworkManager the connection is assumed unmetered.
+func onMeteredConnection() bool {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false
+	}
+	obj := conn.Object("org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager")
+	variant, err := obj.GetProperty("org.freedesktop.NetworkManager.Metered")
+	if err != nil {
+		return false
+	}
+	// NM_METERED_YES = 1, NM_METERED_GUESS_YES = 3
+	metered, ok := variant.Value().(uint32)
+	return ok && (metered == 1 || metered == 3)
+}
+
+// notifyUpdates shows a desktop notification about available updates.
+// Clicking it brings up the Updates view.
+func (a *App) notifyUpdates(count int) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		log.Printf("Error connecting to session bus: %v", err)
+		return
+	}
+
+	summary := "1 update available"
+	if count != 1 {
+		summary = fmt.Sprintf("%d updates available", count)
+	}
+
+	obj := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := obj.Call("org.freedesktop.Notifications.Notify", 0,
+		"Arka Package Manager", uint32(0), "system-software-update",
+		summary, "Click to review the available updates.",
+		[]string{"default", "Open Updates"},
+		map[string]dbus.Variant{"category": dbus.MakeVariant("x-apm.update")},
+		int32(-1))
+	if call.Err != nil {
+		log.Printf("Error sending notification: %v", call.Err)
+		return
+	}
+	var id uint32
+	if err := call.Store(&id); err != nil {
+		return
+	}
+
+	go a.waitForNotificationAction(conn, id)
+}
+
+// waitForNotificationAction opens the Updates view when the notification
+// with the given id is clicked.
+func (a *App) waitForNotificationAction(conn *dbus.Conn, id uint32) {
+	rules := []dbus.MatchOption{
+		dbus.WithMatchInterface("org.freedesktop.Notifications"),
+		dbus.WithMatchObjectPath("/org/freedesktop/Notifications"),
+	}
+	if err := conn.AddMatchSignal(rules...); err != nil {
+		return
+	}
+	defer conn.RemoveMatchSignal(rules...)
+
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case signal := <-signals:
+			if len(signal.Body) == 0 {
+				continue
+			}
+			if signalID, ok := signal.Body[0].(uint32); !ok || signalID != id {
+				continue
+			}
+			switch signal.Name {
+			case "org.freedesktop.Notifications.ActionInvoked":
+				runtime.WindowShow(a.ctx)
+				a.emit("navigate", "updates")
+				return
+			case "org.freedesktop.Notifications.NotificationClosed":
+				return
+			}
+		}
+	}
+}
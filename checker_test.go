@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestNotifyOnlyNewUpdates(t *testing.T) {
+	checks := []struct {
+		updates []UpdateInfo
+		notify  bool
+	}{
+		{[]UpdateInfo{{Name: "linux"}, {Name: "mesa"}}, true},
+		{[]UpdateInfo{{Name: "linux"}, {Name: "mesa"}}, false},
+		{[]UpdateInfo{{Name: "linux"}}, false},
+		{[]UpdateInfo{{Name: "linux"}, {Name: "firefox", Ignored: true}}, false},
+		{[]UpdateInfo{{Name: "linux"}, {Name: "mesa"}}, true},
+		{nil, false},
+	}
+
+	var lastSeen map[string]bool
+	for i, check := range checks {
+		seen := pendingUpdates(check.updates)
+		if got := hasNew(seen, lastSeen); got != check.notify {
+			t.Errorf("check %d: notify = %v, want %v", i+1, got, check.notify)
+		}
+		lastSeen = seen
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+const cliUsage = `Usage: apm <command> [arguments] [--json]
+
+Commands:
+  search <query>         search the repositories and the AUR
+  info <package>...      show package details
+  install <package>...   install packages
+  remove [--yes] <package>...
+                         remove packages
+  updates                list available updates
+  upgrade [--yes]        upgrade the whole system
+  serve [socket]         serve the HTTP API on a Unix socket
+
+Without a command the graphical interface is started.
+`
+
+// cliCommands are the subcommands that run without starting Wails.
+var cliCommands = map[string]func(a *App, args []string, jsonOut bool, out io.Writer) error{
+	"search":  cliSearch,
+	"info":    cliInfo,
+	"install": cliInstall,
+	"remove":  cliRemove,
+	"updates": cliUpdates,
+	"upgrade": cliUpgrade,
+	"serve":   cliServe,
+}
+
+// isCLI reports whether the arguments ask for a command line operation.
+func isCLI(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	_, ok := cliCommands[args[0]]
+	return ok || args[0] == "help" || args[0] == "-h" || args[0] == "--help"
+}
+
+// runCLI runs a command line operation and returns the process exit code.
+func runCLI(args []string) int {
+	run, ok := cliCommands[args[0]]
+	if !ok {
+		fmt.Print(cliUsage)
+		return 0
+	}
+
+	jsonOut := false
+	var rest []string
+	for _, arg := range args[1:] {
+		if arg == "--json" {
+			jsonOut = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a, err := NewHeadlessApp(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer releaseHandle()
+
+	if err := run(a, rest, jsonOut, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func cliSearch(a *App, args []string, jsonOut bool, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apm search <query>")
+	}
+	results := searchPackages(a.ctx, strings.Join(args, " "), false)
+	if jsonOut {
+		return writeJSON(out, results)
+	}
+	for _, pkg := range results {
+		stale := ""
+		if pkg.Stale {
+			stale = " (cached)"
+		}
+		fmt.Fprintf(out, "%s/%s %s%s\n", pkg.Repository, pkg.Name, pkg.Version, stale)
+		if pkg.Description != "" {
+			fmt.Fprintf(out, "    %s\n", pkg.Description)
+		}
+	}
+	return nil
+}
+
+func cliInfo(a *App, args []string, jsonOut bool, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apm info <package>...")
+	}
+	var results []PackageInfo
+	for _, name := range args {
+		pkg, err := a.GetPackageInfo(name)
+		if err != nil {
+			return err
+		}
+		results = append(results, *pkg)
+	}
+	if jsonOut {
+		return writeJSON(out, results)
+	}
+	for i, pkg := range results {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Name\t%s\n", pkg.Name)
+		fmt.Fprintf(w, "Version\t%s\n", pkg.Version)
+		fmt.Fprintf(w, "Description\t%s\n", pkg.Description)
+		fmt.Fprintf(w, "Repository\t%s\n", pkg.Repository)
+		fmt.Fprintf(w, "Maintainer\t%s\n", pkg.Maintainer)
+		fmt.Fprintf(w, "URL\t%s\n", pkg.UpstreamURL)
+		fmt.Fprintf(w, "Depends On\t%s\n", strings.Join(pkg.DependList, " "))
+		fmt.Fprintf(w, "Required By\t%s\n", strings.Join(pkg.RequiredBy, " "))
+		fmt.Fprintf(w, "Optional For\t%s\n", strings.Join(pkg.OptionalFor, " "))
+		fmt.Fprintf(w, "Last Updated\t%s\n", pkg.LastUpdated)
+		w.Flush()
+	}
+	return nil
+}
+
+func cliInstall(a *App, args []string, jsonOut bool, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apm install <package>...")
+	}
+	return cliTransaction(args, jsonOut, out, a.Install, true)
+}
+
+func cliRemove(a *App, args []string, jsonOut bool, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apm remove [--yes] <package>...")
+	}
+	var pkgs []string
+	confirmed := false
+	for _, arg := range args {
+		if arg == "--yes" || arg == "-y" {
+			confirmed = true
+			continue
+		}
+		pkgs = append(pkgs, arg)
+	}
+
+	// Removal skips dependency checks, so refuse to break other packages
+	// unless confirmed
+	checks, err := a.checkRemoval(pkgs)
+	if err != nil {
+		return err
+	}
+	broken := 0
+	for _, check := range checks {
+		if len(check.RequiredBy) > 0 {
+			broken++
+			fmt.Fprintf(os.Stderr, "warning: %s is required by %s\n", check.Name, strings.Join(check.RequiredBy, ", "))
+		}
+		if len(check.OptionalFor) > 0 {
+			fmt.Fprintf(os.Stderr, "note: %s is optional for %s\n", check.Name, strings.Join(check.OptionalFor, ", "))
+		}
+	}
+	if broken > 0 && !confirmed {
+		return fmt.Errorf("removing would break other packages; rerun with --yes to remove anyway")
+	}
+	return cliTransaction(pkgs, jsonOut, out, a.Uninstall, false)
+}
+
+// cliTransaction runs op for every package and checks the outcome against the
+// local database, as yay can exit successfully without making the change.
+func cliTransaction(pkgs []string, jsonOut bool, out io.Writer, op func(string) error, wantInstalled bool) error {
+	type result struct {
+		Name    string `json:"name"`
+		Success bool   `json:"success"`
+		Error   string `json:"error,omitempty"`
+	}
+	var results []result
+	failed := 0
+	for _, pkg := range pkgs {
+		r := result{Name: pkg, Success: true}
+		if err := op(pkg); err != nil {
+			r.Success, r.Error = false, err.Error()
+		} else if installed, err := packageInstalled(pkg); err != nil {
+			r.Success, r.Error = false, err.Error()
+		} else if installed != wantInstalled {
+			r.Success = false
+		}
+		if !r.Success {
+			failed++
+		}
+		results = append(results, r)
+	}
+
+	if jsonOut {
+		if err := writeJSON(out, results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			status := "done"
+			if r.Error != "" {
+				status = "failed: " + r.Error
+			} else if !r.Success {
+				status = "failed"
+			}
+			fmt.Fprintf(out, "%s: %s\n", r.Name, status)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages failed", failed, len(pkgs))
+	}
+	return nil
+}
+
+func cliUpdates(a *App, args []string, jsonOut bool, out io.Writer) error {
+	report, err := a.CheckUpdates()
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		return writeJSON(out, report)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, update := range report.Updates {
+		var notes []string
+		if update.Kind != updateKindUpgrade {
+			notes = append(notes, update.Kind)
+		}
+		if update.Ignored {
+			notes = append(notes, "ignored: "+update.IgnoreReason)
+		}
+		if update.Held {
+			notes = append(notes, "held")
+		}
+		note := ""
+		if len(notes) > 0 {
+			note = "[" + strings.Join(notes, ", ") + "]"
+		}
+		fmt.Fprintf(w, "%s/%s\t%s -> %s\t%s\n", update.Repository, update.Name, update.OldVersion, update.NewVersion, note)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d updates, %s to download, %s size change\n",
+		report.Summary.Count, a.HumanReadableSize(report.Summary.TotalDownload), a.HumanReadableSize(report.Summary.TotalSizeChange))
+	for _, item := range report.News {
+		fmt.Fprintf(out, "news: %s (%s)\n", item.Title, item.Link)
+	}
+	return nil
+}
+
+func cliUpgrade(a *App, args []string, jsonOut bool, out io.Writer) error {
+	confirmed := false
+	for _, arg := range args {
+		if arg == "--yes" || arg == "-y" {
+			confirmed = true
+		}
+	}
+
+	// UpdateAllPkg refuses to run while news are unread, so show them first
+	news, err := a.GetUnreadNews()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: could not check Arch news:", err)
+	}
+	if len(news) > 0 && !confirmed {
+		if jsonOut {
+			writeJSON(out, news)
+		} else {
+			for _, item := range news {
+				fmt.Fprintf(out, "%s\n    %s\n", item.Title, item.Link)
+			}
+		}
+		return fmt.Errorf("%d unread news items; read them and rerun with --yes", len(news))
+	}
+	if len(news) > 0 {
+		var links []string
+		for _, item := range news {
+			links = append(links, item.Link)
+		}
+		if err := a.AcknowledgeNews(links); err != nil {
+			return err
+		}
+	}
+
+	err = a.UpdateAllPkg()
+	if jsonOut {
+		result := map[string]interface{}{"success": err == nil}
+		if err != nil {
+			result["error"] = err.Error()
+		}
+		if werr := writeJSON(out, result); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// satisfies reports whether pkg satisfies dep, either by its own name and
+// version or through one of its provides.
+func satisfies(pkg alpm.IPackage, dep alpm.Depend) bool {
+	if pkg.Name() == dep.Name && versionSatisfies(pkg.Version(), dep) {
+		return true
+	}
+	for _, provide := range pkg.Provides().Slice() {
+		if provide.Name != dep.Name {
+			continue
+		}
+		// An unversioned provide only satisfies unversioned dependencies.
+		if dep.Mod == alpm.DepModAny || dep.Mod == 0 || (provide.Version != "" && versionSatisfies(provide.Version, dep)) {
+			return true
+		}
+	}
+	return false
+}
+
+func versionSatisfies(version string, dep alpm.Depend) bool {
+	cmp := alpm.VerCmp(version, dep.Version)
+	switch dep.Mod {
+	case alpm.DepModEq:
+		return cmp == 0
+	case alpm.DepModGE:
+		return cmp >= 0
+	case alpm.DepModLE:
+		return cmp <= 0
+	case alpm.DepModGT:
+		return cmp > 0
+	case alpm.DepModLT:
+		return cmp < 0
+	default:
+		return true
+	}
+}
+
+// UninstallCheck lists the installed packages that need a package, so the
+// user can be warned before removing it.
+type UninstallCheck struct {
+	Name        string   `json:"name"`
+	RequiredBy  []string `json:"requiredBy"`
+	OptionalFor []string `json:"optionalFor"`
+}
+
+// CheckUninstall returns the installed packages that depend on name.
+// Uninstall skips dependency checks, so a non-empty RequiredBy means removing
+// the package breaks those packages.
+func (a *App) CheckUninstall(name string) (*UninstallCheck, error) {
+	h, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+	pkg := localDB.Pkg(name)
+	if pkg == nil {
+		return nil, fmt.Errorf("package %s is not installed", name)
+	}
+	return &UninstallCheck{
+		Name:        name,
+		RequiredBy:  pkg.ComputeRequiredBy(),
+		OptionalFor: pkg.ComputeOptionalFor(),
+	}, nil
+}
+
+// checkRemoval runs CheckUninstall for every package removed together.
+// RequiredBy only lists the packages that are not removed with them.
+func (a *App) checkRemoval(pkgs []string) ([]UninstallCheck, error) {
+	var checks []UninstallCheck
+	for _, pkg := range pkgs {
+		check, err := a.CheckUninstall(pkg)
+		if err != nil {
+			return nil, err
+		}
+		var dependents []string
+		for _, name := range check.RequiredBy {
+			if !contains(pkgs, name) {
+				dependents = append(dependents, name)
+			}
+		}
+		check.RequiredBy = dependents
+		checks = append(checks, *check)
+	}
+	return checks, nil
+}
+
+// addReverseDeps fills in RequiredBy and OptionalFor of packages[i] for the
+// package named names[i], as the Name of a search result can be the name
+// that was searched for. Installed packages are looked up in the local
+// database; other packages in the sync databases, where the result lists the
+// repository packages that depend on them.
+func addReverseDeps(packages []PackageInfo, names []string) error {
+	h, syncDBs, err := openSystemDB()
+	if err != nil {
+		return err
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return fmt.Errorf("failed to get local DB: %v", err)
+	}
+	reverseDeps(localDB, syncDBs, packages, names)
+	return nil
+}
+
+// reverseDeps does the lookups of addReverseDeps.
+func reverseDeps(localDB alpm.IDB, syncDBs []alpm.IDB, packages []PackageInfo, names []string) {
+	for i := range packages {
+		if names[i] == "" {
+			continue
+		}
+		pkg := localDB.Pkg(names[i])
+		if pkg == nil {
+			for _, db := range syncDBs {
+				if pkg = db.Pkg(names[i]); pkg != nil {
+					break
+				}
+			}
+		}
+		if pkg == nil {
+			continue
+		}
+		packages[i].RequiredBy = pkg.ComputeRequiredBy()
+		packages[i].OptionalFor = pkg.ComputeOptionalFor()
+	}
+}
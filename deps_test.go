@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+func TestSatisfies(t *testing.T) {
+	pkg := &fakePkg{name: "bash", version: "5.2.015-1", provides: []alpm.Depend{
+		{Name: "sh"},
+		{Name: "libreadline.so", Version: "8", Mod: alpm.DepModEq},
+	}}
+
+	for dep, want := range map[alpm.Depend]bool{
+		{Name: "bash"}: true,
+		{Name: "bash", Version: "5.0", Mod: alpm.DepModGE}:       true,
+		{Name: "bash", Version: "5.2.015-1", Mod: alpm.DepModEq}: true,
+		{Name: "bash", Version: "5.2.015", Mod: alpm.DepModLT}:   false,
+		{Name: "sh"}: true,
+		{Name: "sh", Version: "1", Mod: alpm.DepModGE}:             false,
+		{Name: "libreadline.so", Version: "8", Mod: alpm.DepModGE}: true,
+		{Name: "libreadline.so", Version: "8", Mod: alpm.DepModGT}: false,
+		{Name: "zsh"}: false,
+		{Name: "libreadline.so", Version: "7", Mod: alpm.DepModLE}: false,
+	} {
+		if got := satisfies(pkg, dep); got != want {
+			t.Errorf("satisfies(bash, %s%s%s) = %v, want %v", dep.Name, dep.Mod, dep.Version, got, want)
+		}
+	}
+}
+
+func TestReverseDeps(t *testing.T) {
+	local := newFakeDB("local",
+		&fakePkg{name: "bash", provides: []alpm.Depend{{Name: "sh"}}},
+		&fakePkg{name: "git", depends: []alpm.Depend{{Name: "sh"}}, optDepends: []alpm.Depend{{Name: "perl"}}},
+		&fakePkg{name: "perl"},
+		&fakePkg{name: "makepkg", depends: []alpm.Depend{{Name: "bash"}}},
+	)
+	// Sync packages are resolved against their own repository
+	syncDBs := []alpm.IDB{
+		newFakeDB("extra",
+			&fakePkg{name: "bash"},
+			&fakePkg{name: "zsh"},
+			&fakePkg{name: "oh-my-zsh", depends: []alpm.Depend{{Name: "zsh"}}},
+			&fakePkg{name: "zsh-theme", optDepends: []alpm.Depend{{Name: "zsh"}}},
+		),
+	}
+
+	// Search results can be named after the query; names are the packages
+	// they were resolved to, empty when there is none
+	packages := []PackageInfo{{Name: "bash"}, {Name: "perl"}, {Name: "zsh"}, {Name: "fish"}, {Name: "sh"}}
+	reverseDeps(local, syncDBs, packages, []string{"bash", "perl", "zsh", "fish", ""})
+
+	want := []PackageInfo{
+		{Name: "bash", RequiredBy: []string{"git", "makepkg"}},
+		{Name: "perl", OptionalFor: []string{"git"}},
+		{Name: "zsh", RequiredBy: []string{"oh-my-zsh"}, OptionalFor: []string{"zsh-theme"}},
+		{Name: "fish"},
+		{Name: "sh"},
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("reverseDeps() =\n%+v\nwant\n%+v", packages, want)
+	}
+}
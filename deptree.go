@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// DependencyNode is a node of the tree returned by GetDependencyTree.
+type DependencyNode struct {
+	// Name is the package name, or the dependency string for a missing node.
+	Name string `json:"name"`
+	// Dependency is the dependency string that led to this node when it
+	// differs from the package name, e.g. "sh" for bash or "libfoo.so=3".
+	Dependency string `json:"dependency,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Installed  bool   `json:"installed"`
+	// Missing is set when no installed or repository package satisfies the
+	// dependency.
+	Missing bool `json:"missing,omitempty"`
+	// Cycle is set when the package is one of its own ancestors. Its children
+	// are not listed.
+	Cycle bool `json:"cycle,omitempty"`
+	// Repeated is set when the package was already expanded elsewhere in the
+	// tree. Its children are not listed again.
+	Repeated bool `json:"repeated,omitempty"`
+	// Truncated is set when the depth limit cut off the children.
+	Truncated bool              `json:"truncated,omitempty"`
+	Children  []*DependencyNode `json:"children,omitempty"`
+}
+
+// depTree resolves packages for GetDependencyTree.
+type depTree struct {
+	localDB  alpm.IDB
+	syncDBs  alpm.IDBList
+	maxDepth int
+	reverse  bool
+	expanded map[string]bool
+}
+
+// GetDependencyTree returns the dependency tree of a package, like pactree.
+// Dependencies are resolved through providers, preferring installed packages
+// over the sync repositories. With reverse set it lists the packages that
+// depend on the package instead. A depth of 0 or less means no limit.
+func (a *App) GetDependencyTree(name string, depth int, reverse bool) (*DependencyNode, error) {
+	h, _, err := openSystemDB()
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+	syncDBs, err := h.SyncDBs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync DBs: %v", err)
+	}
+
+	t := &depTree{
+		localDB:  localDB,
+		syncDBs:  syncDBs,
+		maxDepth: depth,
+		reverse:  reverse,
+		expanded: make(map[string]bool),
+	}
+	pkg, installed := t.resolve(name)
+	if pkg == nil {
+		return nil, fmt.Errorf("package %s not found", name)
+	}
+	return t.build(pkg, installed, "", 0, map[string]bool{}), nil
+}
+
+// resolve finds the package satisfying a dependency string, installed first.
+func (t *depTree) resolve(dep string) (alpm.IPackage, bool) {
+	if pkg, err := t.localDB.PkgCache().FindSatisfier(dep); err == nil && pkg != nil {
+		return pkg, true
+	}
+	if pkg, err := t.syncDBs.FindSatisfier(dep); err == nil && pkg != nil {
+		return pkg, false
+	}
+	return nil, false
+}
+
+func (t *depTree) build(pkg alpm.IPackage, installed bool, dep string, depth int, ancestors map[string]bool) *DependencyNode {
+	node := &DependencyNode{
+		Name:       pkg.Name(),
+		Version:    pkg.Version(),
+		Repository: pkg.DB().Name(),
+		Installed:  installed,
+	}
+	if dep != "" && dep != pkg.Name() {
+		node.Dependency = dep
+	}
+
+	switch {
+	case ancestors[pkg.Name()]:
+		node.Cycle = true
+		return node
+	case t.expanded[pkg.Name()]:
+		node.Repeated = true
+		return node
+	}
+
+	children := t.children(pkg)
+	if len(children) == 0 {
+		return node
+	}
+	if t.maxDepth > 0 && depth >= t.maxDepth {
+		node.Truncated = true
+		return node
+	}
+	// Only packages whose children are listed count as expanded, so that a
+	// package truncated here is still expanded where it appears at a
+	// shallower depth
+	t.expanded[pkg.Name()] = true
+
+	ancestors[pkg.Name()] = true
+	defer delete(ancestors, pkg.Name())
+	for _, child := range children {
+		childPkg, childInstalled := t.resolve(child)
+		if childPkg == nil {
+			node.Children = append(node.Children, &DependencyNode{Name: child, Missing: true})
+			continue
+		}
+		node.Children = append(node.Children, t.build(childPkg, childInstalled, child, depth+1, ancestors))
+	}
+	return node
+}
+
+// children returns the dependency strings below pkg: its dependencies, or
+// the names of the packages requiring it in reverse mode.
+func (t *depTree) children(pkg alpm.IPackage) []string {
+	if t.reverse {
+		return pkg.ComputeRequiredBy()
+	}
+	var deps []string
+	for _, dep := range pkg.Depends().Slice() {
+		deps = append(deps, dep.String())
+	}
+	return deps
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// formatTree renders a dependency tree one node per line, indented by depth.
+func formatTree(node *DependencyNode) string {
+	var b strings.Builder
+	var walk func(node *DependencyNode, indent string)
+	walk = func(node *DependencyNode, indent string) {
+		fmt.Fprintf(&b, "%s%s", indent, node.Name)
+		if node.Dependency != "" {
+			fmt.Fprintf(&b, " (%s)", node.Dependency)
+		}
+		if node.Repository != "" && !node.Installed {
+			fmt.Fprintf(&b, " [%s]", node.Repository)
+		}
+		for flag, set := range map[string]bool{"missing": node.Missing, "cycle": node.Cycle, "repeated": node.Repeated, "truncated": node.Truncated} {
+			if set {
+				b.WriteString(" " + flag)
+			}
+		}
+		b.WriteByte('\n')
+		for _, child := range node.Children {
+			walk(child, indent+"  ")
+		}
+	}
+	walk(node, "")
+	return b.String()
+}
+
+func newTestDepTree(depth int, reverse bool) *depTree {
+	deps := func(names ...string) []alpm.Depend {
+		var deps []alpm.Depend
+		for _, name := range names {
+			deps = append(deps, alpm.Depend{Name: name})
+		}
+		return deps
+	}
+	local := newFakeDB("local",
+		&fakePkg{name: "app", depends: append(deps("liba", "libb", "sh", "newdep"),
+			alpm.Depend{Name: "libgone", Version: "2", Mod: alpm.DepModGE})},
+		&fakePkg{name: "liba", depends: deps("libc")},
+		&fakePkg{name: "libb", depends: deps("libc")},
+		&fakePkg{name: "libc", depends: deps("glibc")},
+		&fakePkg{name: "glibc"},
+		&fakePkg{name: "bash", provides: deps("sh"), depends: deps("readline")},
+		&fakePkg{name: "readline", depends: deps("bash")},
+		&fakePkg{name: "mid", depends: deps("libc")},
+		&fakePkg{name: "top", depends: deps("mid", "libc")},
+	)
+	syncDBs := fakeDBList{
+		newFakeDB("extra", &fakePkg{name: "newdep"}, &fakePkg{name: "dash", provides: deps("sh")}),
+	}
+	return &depTree{
+		localDB:  local,
+		syncDBs:  syncDBs,
+		maxDepth: depth,
+		reverse:  reverse,
+		expanded: make(map[string]bool),
+	}
+}
+
+func TestDepTreeBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		root    string
+		depth   int
+		reverse bool
+		want    string
+	}{
+		{
+			name: "full tree",
+			root: "app",
+			want: `app
+  liba
+    libc
+      glibc
+  libb
+    libc repeated
+  bash (sh)
+    readline
+      bash cycle
+  newdep [extra]
+  libgone>=2 missing
+`,
+		},
+		{
+			name:  "depth limit",
+			root:  "app",
+			depth: 1,
+			want: `app
+  liba truncated
+  libb truncated
+  bash (sh) truncated
+  newdep [extra]
+  libgone>=2 missing
+`,
+		},
+		{
+			name:    "reverse",
+			root:    "glibc",
+			reverse: true,
+			want: `glibc
+  libc
+    liba
+      app
+    libb
+      app
+    mid
+      top
+    top
+`,
+		},
+		{
+			name:  "truncated package expanded higher up",
+			root:  "top",
+			depth: 2,
+			want: `top
+  mid
+    libc truncated
+  libc
+    glibc
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := newTestDepTree(tt.depth, tt.reverse)
+			pkg, installed := tree.resolve(tt.root)
+			if pkg == nil {
+				t.Fatalf("resolve(%q) found nothing", tt.root)
+			}
+			got := formatTree(tree.build(pkg, installed, "", 0, map[string]bool{}))
+			if got != tt.want {
+				t.Errorf("tree:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
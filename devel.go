@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	updateKindDevel = "devel"
+	develStateFile  = "vcs.json"
+
+	// develRevisionTTL is how long an upstream revision is reused before
+	// asking the remote again, and develConcurrency bounds the concurrent
+	// git ls-remote calls.
+	develRevisionTTL = 15 * time.Minute
+	develConcurrency = 8
+)
+
+// develSuffixes are the package name suffixes of VCS packages.
+var develSuffixes = []string{"-git", "-svn", "-hg", "-bzr", "-darcs", "-fossil", "-cvs"}
+
+// vcsSource is the tracked state of a single VCS source. The format matches
+// the entries of yay's vcs.json so both files can be read the same way.
+type vcsSource struct {
+	Protocols []string `json:"protocols"`
+	Branch    string   `json:"branch"`
+	SHA       string   `json:"sha"`
+	// Version is the installed package version the SHA was recorded for.
+	// yay does not store it; it is only used for our own entries.
+	Version string `json:"version,omitempty"`
+}
+
+// vcsInfo maps package names to their sources, keyed by URL without scheme.
+type vcsInfo map[string]map[string]vcsSource
+
+// cachedRevision is an upstream revision returned by remoteRevision.
+type cachedRevision struct {
+	sha     string
+	fetched time.Time
+}
+
+var (
+	develMu sync.Mutex
+
+	revisionsMu sync.Mutex
+	revisions   = make(map[string]cachedRevision)
+)
+
+func isDevelPackage(name string) bool {
+	for _, suffix := range develSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDevelUpdates reports devel packages whose upstream source moved past
+// the revision they were built from. installed maps package names to
+// installed versions and bases maps them to their AUR package base.
+//
+// Revisions recorded by yay when it built a package are used when available.
+// Otherwise the sources are read from the AUR .SRCINFO and the current
+// upstream revision is recorded, so a change is reported from the next check
+// on. Only git sources can be checked.
+func (a *App) checkDevelUpdates(installed, bases map[string]string) []UpdateInfo {
+	develMu.Lock()
+	defer develMu.Unlock()
+
+	yayInfo := readVCSInfo(yayVCSPath())
+	ourPath, err := develStatePath()
+	if err != nil {
+		log.Printf("Error checking devel updates: %v", err)
+		return nil
+	}
+	ourInfo := readVCSInfo(ourPath)
+
+	var (
+		updates  []UpdateInfo
+		recorded = make(vcsInfo)
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, develConcurrency)
+	for name, version := range installed {
+		if !isDevelPackage(name) {
+			continue
+		}
+
+		sources, tracked := yayInfo[name]
+		if !tracked {
+			sources = ourInfo[name]
+			// Re-record when the package was rebuilt since the last check.
+			tracked = len(sources) > 0 && sourcesVersion(sources) == version
+		}
+
+		wg.Add(1)
+		go func(name, version string, sources map[string]vcsSource, tracked bool) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-a.ctx.Done():
+				return
+			}
+
+			if !tracked {
+				if sources := a.recordDevelSources(bases[name], version); sources != nil {
+					mu.Lock()
+					recorded[name] = sources
+					mu.Unlock()
+				}
+				return
+			}
+			if update, ok := a.develUpdate(name, version, sources); ok {
+				mu.Lock()
+				updates = append(updates, update)
+				mu.Unlock()
+			}
+		}(name, version, sources, tracked)
+	}
+	wg.Wait()
+
+	if len(recorded) > 0 {
+		for name, sources := range recorded {
+			ourInfo[name] = sources
+		}
+		if err := writeVCSInfo(ourPath, ourInfo); err != nil {
+			log.Printf("Error saving devel state: %v", err)
+		}
+	}
+	return updates
+}
+
+// develUpdate reports an update of a devel package when the upstream
+// revision of one of its sources changed.
+func (a *App) develUpdate(name, version string, sources map[string]vcsSource) (UpdateInfo, bool) {
+	for key, source := range sources {
+		sha, err := a.remoteRevision(key, source)
+		if err != nil {
+			log.Printf("Error checking upstream of %s: %v", name, err)
+			continue
+		}
+		if sha == source.SHA {
+			continue
+		}
+		newVersion := sha
+		if len(newVersion) > 7 {
+			newVersion = newVersion[:7]
+		}
+		return UpdateInfo{
+			Name:        name,
+			OldVersion:  version,
+			NewVersion:  "latest-commit " + newVersion,
+			Repository:  "AUR",
+			SizeUnknown: true,
+			Kind:        updateKindDevel,
+		}, true
+	}
+	return UpdateInfo{}, false
+}
+
+func sourcesVersion(sources map[string]vcsSource) string {
+	for _, source := range sources {
+		return source.Version
+	}
+	return ""
+}
+
+// recordDevelSources reads the git sources of pkgbase from the AUR and
+// records their current upstream revisions.
+func (a *App) recordDevelSources(pkgbase, version string) map[string]vcsSource {
+	if pkgbase == "" {
+		return nil
+	}
+	// Not an RPC call, so it does not count against the AUR rate limit
+	srcinfo, err := httpGet(a.ctx, "https://aur.archlinux.org/cgit/aur.git/plain/.SRCINFO?h="+url.QueryEscape(pkgbase))
+	if err != nil {
+		log.Printf("Error fetching .SRCINFO of %s: %v", pkgbase, err)
+		return nil
+	}
+
+	sources := make(map[string]vcsSource)
+	for key, source := range parseGitSources(srcinfo) {
+		sha, err := a.remoteRevision(key, source)
+		if err != nil {
+			log.Printf("Error checking upstream of %s: %v", pkgbase, err)
+			continue
+		}
+		source.SHA = sha
+		source.Version = version
+		sources[key] = source
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return sources
+}
+
+// parseGitSources extracts the git sources that follow a branch from a
+// .SRCINFO. Sources pinned to a tag or commit never change and are skipped.
+func parseGitSources(srcinfo []byte) map[string]vcsSource {
+	sources := make(map[string]vcsSource)
+	scanner := bufio.NewScanner(bytes.NewReader(srcinfo))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if !ok || (key != "source" && !strings.HasPrefix(key, "source_")) {
+			continue
+		}
+		// Strip the optional "name::" prefix.
+		if _, rest, found := strings.Cut(value, "::"); found {
+			value = rest
+		}
+		if !strings.HasPrefix(value, "git+") {
+			continue
+		}
+		value = strings.TrimPrefix(value, "git+")
+
+		branch := "HEAD"
+		if base, fragment, found := strings.Cut(value, "#"); found {
+			value = base
+			kind, ref, _ := strings.Cut(fragment, "=")
+			if kind != "branch" {
+				continue
+			}
+			branch = ref
+		}
+		value, _, _ = strings.Cut(value, "?")
+
+		scheme, rest, found := strings.Cut(value, "://")
+		if !found {
+			continue
+		}
+		sources[rest] = vcsSource{Protocols: []string{scheme}, Branch: branch}
+	}
+	return sources
+}
+
+// remoteRevision returns the commit the tracked branch of a source points to.
+// Revisions are cached for develRevisionTTL, as several packages may build
+// from the same repository.
+func (a *App) remoteRevision(key string, source vcsSource) (string, error) {
+	if len(source.Protocols) == 0 {
+		return "", fmt.Errorf("no protocol for %s", key)
+	}
+	branch := source.Branch
+	if branch == "" {
+		branch = "HEAD"
+	}
+	remote := source.Protocols[0] + "://" + key
+
+	revisionsMu.Lock()
+	cached, ok := revisions[remote+"#"+branch]
+	revisionsMu.Unlock()
+	if ok && time.Since(cached.fetched) < develRevisionTTL {
+		return cached.sha, nil
+	}
+
+	sha, err := a.lsRemote(remote, branch)
+	if err != nil {
+		return "", err
+	}
+	revisionsMu.Lock()
+	revisions[remote+"#"+branch] = cachedRevision{sha: sha, fetched: time.Now()}
+	revisionsMu.Unlock()
+	return sha, nil
+}
+
+// lsRemote asks a git remote which commit branch points to.
+func (a *App) lsRemote(remote, branch string) (string, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", remote, branch)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote failed: %w", err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("branch %s not found", branch)
+	}
+	return fields[0], nil
+}
+
+func yayVCSPath() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(base, "yay", develStateFile)
+}
+
+func develStatePath() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, develStateFile), nil
+}
+
+func readVCSInfo(path string) vcsInfo {
+	info := make(vcsInfo)
+	if path == "" {
+		return info
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		log.Printf("Error reading %s: %v", path, err)
+	}
+	return info
+}
+
+func writeVCSInfo(path string, info vcsInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
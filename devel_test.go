@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGitSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		srcinfo string
+		want    map[string]vcsSource
+	}{
+		{
+			name:    "default branch",
+			srcinfo: "pkgbase = foo-git\n\tsource = git+https://github.com/foo/foo.git\n",
+			want: map[string]vcsSource{
+				"github.com/foo/foo.git": {Protocols: []string{"https"}, Branch: "HEAD"},
+			},
+		},
+		{
+			name:    "named source on a branch",
+			srcinfo: "\tsource = foo::git+https://gitlab.com/foo/foo.git#branch=develop\n",
+			want: map[string]vcsSource{
+				"gitlab.com/foo/foo.git": {Protocols: []string{"https"}, Branch: "develop"},
+			},
+		},
+		{
+			name:    "pinned sources are skipped",
+			srcinfo: "\tsource = git+https://example.org/a.git#tag=v1.0\n\tsource = git+https://example.org/b.git#commit=abc123\n",
+			want:    map[string]vcsSource{},
+		},
+		{
+			name:    "query and architecture specific sources",
+			srcinfo: "\tsource_x86_64 = git+ssh://example.org/c.git?signed#branch=main\n",
+			want: map[string]vcsSource{
+				"example.org/c.git": {Protocols: []string{"ssh"}, Branch: "main"},
+			},
+		},
+		{
+			name:    "non-git sources",
+			srcinfo: "\tsource = https://example.org/foo-1.0.tar.gz\n\tsource = svn+https://example.org/svn/foo\n\tsha256sums = SKIP\n",
+			want:    map[string]vcsSource{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseGitSources([]byte(tt.srcinfo)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGitSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
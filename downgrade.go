@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+const (
+	versionSourceCache   = "cache"
+	versionSourceArchive = "archive"
+)
+
+// archiveURL is the Arch Linux Archive. It can be overridden with
+// APM_ARCHIVE_URL, e.g. to point at a local mirror with the same layout.
+var archiveURL = envOr("APM_ARCHIVE_URL", "https://archive.archlinux.org")
+
+var archiveLinkRe = regexp.MustCompile(`href="([^"]+\.pkg\.tar\.[a-z0-9]+)"`)
+
+// AvailableVersion is a version of a package that can be installed with
+// Downgrade.
+type AvailableVersion struct {
+	Version string `json:"version"`
+	// Source is "cache" for the package cache or "archive" for the Arch
+	// Linux Archive.
+	Source string `json:"source"`
+	// Location is the package file path or URL.
+	Location  string `json:"location"`
+	Installed bool   `json:"installed"`
+}
+
+// pkgFile is a parsed package file name, name-pkgver-pkgrel-arch.pkg.tar.ext.
+type pkgFile struct {
+	name    string
+	version string
+	arch    string
+}
+
+// ListAvailableVersions returns the versions of a package found in the
+// package cache and the archive, newest first. Versions in both are listed
+// once, from the cache. The archive is skipped when it cannot be reached.
+func (a *App) ListAvailableVersions(name string) ([]AvailableVersion, error) {
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return nil, fmt.Errorf("invalid package name %q", name)
+	}
+	conf, _, err := paconf.ParseFile(pacmanConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pacman config: %v", err)
+	}
+	arches := pkgArchitectures(conf)
+
+	installed := ""
+	if h, err := alpm.Initialize("/", pacmanDBPath); err == nil {
+		if localDB, err := h.LocalDB(); err == nil {
+			if pkg := localDB.Pkg(name); pkg != nil {
+				installed = pkg.Version()
+			}
+		}
+		h.Release()
+	}
+
+	byVersion := make(map[string]AvailableVersion)
+	add := func(version, source, location string) {
+		if _, ok := byVersion[version]; ok {
+			return
+		}
+		byVersion[version] = AvailableVersion{
+			Version:   version,
+			Source:    source,
+			Location:  location,
+			Installed: version == installed,
+		}
+	}
+
+	for version, location := range cachedPackages(conf, name) {
+		add(version, versionSourceCache, location)
+	}
+
+	links, err := a.archiveLinks(name)
+	if err != nil {
+		log.Printf("Error listing archive versions of %s: %v", name, err)
+	}
+	for _, link := range links {
+		// Epochs are escaped in the URL, e.g. 1%3A2.0-1
+		filename, err := url.PathUnescape(path.Base(link))
+		if err != nil {
+			continue
+		}
+		if file, ok := parsePkgFileName(filename); ok && file.name == name && contains(arches, file.arch) {
+			add(file.version, versionSourceArchive, link)
+		}
+	}
+
+	versions := make([]AvailableVersion, 0, len(byVersion))
+	for _, version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return alpm.VerCmp(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+// Downgrade installs the given version of a package from the cache or the
+// archive. With ignore set the package is also added to the session ignore
+// list so the next upgrade does not undo the downgrade.
+func (a *App) Downgrade(name, version string, ignore bool) error {
+	versions, err := a.ListAvailableVersions(name)
+	if err != nil {
+		return err
+	}
+	var target *AvailableVersion
+	for i := range versions {
+		if versions[i].Version == version {
+			target = &versions[i]
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("version %s of %s is not available", version, name)
+	}
+
+	// pacman -U downloads URLs itself, checking the signature next to them
+	if err := runPrivileged("pacman", "-U", "--noconfirm", target.Location); err != nil {
+		return fmt.Errorf("failed to downgrade %s: %w", name, err)
+	}
+
+	if ignore {
+		return a.IgnoreForSession(name)
+	}
+	return nil
+}
+
+// archiveLinks returns the package file URLs of the archive directory of a
+// package, packages/<first letter>/<name>/.
+func (a *App) archiveLinks(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 15*time.Second)
+	defer cancel()
+
+	base := strings.TrimSuffix(archiveURL, "/") + "/packages/" + name[:1] + "/" + url.PathEscape(name) + "/"
+	data, err := httpGet(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	for _, m := range archiveLinkRe.FindAllStringSubmatch(string(data), -1) {
+		href, err := url.PathUnescape(m[1])
+		if err != nil {
+			continue
+		}
+		links = append(links, base+url.PathEscape(filepath.Base(href)))
+	}
+	return links, nil
+}
+
+// cachedPackages returns the package files of name in the package cache
+// directories that can be installed on this system, by version.
+func cachedPackages(conf *paconf.Config, name string) map[string]string {
+	arches := pkgArchitectures(conf)
+	files := make(map[string]string)
+	for _, dir := range conf.CacheDir {
+		matches, _ := filepath.Glob(filepath.Join(dir, name+"-*.pkg.tar.*"))
+		for _, match := range matches {
+			file, ok := parsePkgFileName(filepath.Base(match))
+			if !ok || file.name != name || !contains(arches, file.arch) {
+				continue
+			}
+			if _, seen := files[file.version]; !seen {
+				files[file.version] = match
+			}
+		}
+	}
+	return files
+}
+
+// parsePkgFileName splits a package file name into its parts. Signatures and
+// partial downloads are rejected.
+func parsePkgFileName(filename string) (pkgFile, bool) {
+	i := strings.Index(filename, ".pkg.tar")
+	if i < 0 || strings.HasSuffix(filename, ".sig") || strings.HasSuffix(filename, ".part") {
+		return pkgFile{}, false
+	}
+	parts := strings.Split(filename[:i], "-")
+	if len(parts) < 4 {
+		return pkgFile{}, false
+	}
+	n := len(parts)
+	return pkgFile{
+		name:    strings.Join(parts[:n-3], "-"),
+		version: parts[n-3] + "-" + parts[n-2],
+		arch:    parts[n-1],
+	}, true
+}
+
+// pkgArchitectures returns the package architectures the system accepts.
+func pkgArchitectures(conf *paconf.Config) []string {
+	arches := []string{"any"}
+	for _, arch := range conf.Architecture {
+		if arch != "auto" {
+			arches = append(arches, arch)
+		}
+	}
+	if len(arches) == 1 {
+		switch runtime.GOARCH {
+		case "amd64":
+			arches = append(arches, "x86_64")
+		case "arm64":
+			arches = append(arches, "aarch64")
+		case "386":
+			arches = append(arches, "i686")
+		default:
+			arches = append(arches, runtime.GOARCH)
+		}
+	}
+	return arches
+}
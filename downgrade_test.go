@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+func TestParsePkgFileName(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     pkgFile
+		ok       bool
+	}{
+		{
+			filename: "vim-9.1.0-1-x86_64.pkg.tar.zst",
+			want:     pkgFile{name: "vim", version: "9.1.0-1", arch: "x86_64"},
+			ok:       true,
+		},
+		{
+			filename: "python-setuptools-1:69.0.3-1-any.pkg.tar.zst",
+			want:     pkgFile{name: "python-setuptools", version: "1:69.0.3-1", arch: "any"},
+			ok:       true,
+		},
+		{
+			filename: "lib32-glibc-2.39-1.1-x86_64.pkg.tar.xz",
+			want:     pkgFile{name: "lib32-glibc", version: "2.39-1.1", arch: "x86_64"},
+			ok:       true,
+		},
+		{filename: "vim-9.1.0-1-x86_64.pkg.tar.zst.sig"},
+		{filename: "vim-9.1.0-1-x86_64.pkg.tar.zst.part"},
+		{filename: "vim-9.1.0-1.tar.gz"},
+		{filename: "vim-1-x86_64.pkg.tar.zst"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parsePkgFileName(tt.filename)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parsePkgFileName(%q) = %+v, %v, want %+v, %v", tt.filename, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPkgArchitectures(t *testing.T) {
+	if got := pkgArchitectures(&paconf.Config{Architecture: []string{"x86_64", "x86_64_v3"}}); !reflect.DeepEqual(got, []string{"any", "x86_64", "x86_64_v3"}) {
+		t.Errorf("explicit architectures: got %v", got)
+	}
+
+	got := pkgArchitectures(&paconf.Config{Architecture: []string{"auto"}})
+	if len(got) != 2 || got[0] != "any" {
+		t.Fatalf("auto architecture: got %v", got)
+	}
+	if runtime.GOARCH == "amd64" && got[1] != "x86_64" {
+		t.Errorf("auto architecture on amd64: got %v", got)
+	}
+}
+
+func TestArchiveLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/packages/p/python-foo/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html><body><pre>
+<a href="../">../</a>
+<a href="python-foo-1%3A1.0-1-any.pkg.tar.zst">python-foo-1:1.0-1-any.pkg.tar.zst</a>
+<a href="python-foo-1%3A1.0-1-any.pkg.tar.zst.sig">python-foo-1:1.0-1-any.pkg.tar.zst.sig</a>
+<a href="python-foo-0.9-2-any.pkg.tar.xz">python-foo-0.9-2-any.pkg.tar.xz</a>
+</pre></body></html>`))
+	}))
+	defer server.Close()
+
+	defer func(url string) { archiveURL = url }(archiveURL)
+	archiveURL = server.URL + "/"
+
+	a := &App{ctx: context.Background()}
+	links, err := a.archiveLinks("python-foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		server.URL + "/packages/p/python-foo/python-foo-1:1.0-1-any.pkg.tar.zst",
+		server.URL + "/packages/p/python-foo/python-foo-0.9-2-any.pkg.tar.xz",
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("archiveLinks() = %v, want %v", links, want)
+	}
+
+	if _, err := a.archiveLinks("missing"); err == nil {
+		t.Error("archiveLinks() of a package without archive directory succeeded")
+	}
+}
+
+func TestCachedPackages(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	create := func(dir string, names ...string) {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	create(first,
+		"vim-9.1.0-1-x86_64.pkg.tar.zst",
+		"vim-9.1.0-1-x86_64.pkg.tar.zst.sig",
+		"vim-9.0.2-1-aarch64.pkg.tar.zst",
+		"vim-runtime-9.1.0-1-x86_64.pkg.tar.zst",
+	)
+	create(second,
+		"vim-9.1.0-1-x86_64.pkg.tar.zst",
+		"vim-9.0.1-2-x86_64.pkg.tar.xz",
+	)
+
+	conf := &paconf.Config{CacheDir: []string{first, second}, Architecture: []string{"x86_64"}}
+	got := cachedPackages(conf, "vim")
+	want := map[string]string{
+		// The first cache directory wins
+		"9.1.0-1": filepath.Join(first, "vim-9.1.0-1-x86_64.pkg.tar.zst"),
+		"9.0.1-2": filepath.Join(second, "vim-9.0.1-2-x86_64.pkg.tar.xz"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cachedPackages() = %v, want %v", got, want)
+	}
+}
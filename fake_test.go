@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// fakePkg is an in-memory alpm.IPackage. Methods that are not overridden
+// panic through the nil embedded interface.
+type fakePkg struct {
+	alpm.IPackage
+	name, version string
+	db            *fakeDB
+	size, isize   int64
+	replaces      []alpm.Depend
+	conflicts     []alpm.Depend
+	provides      []alpm.Depend
+	depends       []alpm.Depend
+	optDepends    []alpm.Depend
+	files         []alpm.File
+}
+
+func (p *fakePkg) Name() string                 { return p.name }
+func (p *fakePkg) Version() string              { return p.version }
+func (p *fakePkg) DB() alpm.IDB                 { return p.db }
+func (p *fakePkg) FileName() string             { return p.name + "-" + p.version + "-x86_64.pkg.tar.zst" }
+func (p *fakePkg) Size() int64                  { return p.size }
+func (p *fakePkg) ISize() int64                 { return p.isize }
+func (p *fakePkg) Groups() alpm.StringList      { return alpm.StringList{} }
+func (p *fakePkg) Replaces() alpm.IDependList   { return fakeDepList(p.replaces) }
+func (p *fakePkg) Conflicts() alpm.IDependList  { return fakeDepList(p.conflicts) }
+func (p *fakePkg) Provides() alpm.IDependList   { return fakeDepList(p.provides) }
+func (p *fakePkg) Depends() alpm.IDependList    { return fakeDepList(p.depends) }
+func (p *fakePkg) ComputeRequiredBy() []string  { return p.db.dependents(p, false) }
+func (p *fakePkg) ComputeOptionalFor() []string { return p.db.dependents(p, true) }
+func (p *fakePkg) Files() []alpm.File           { return p.files }
+
+func (p *fakePkg) SyncNewVersion(l alpm.IDBList) alpm.IPackage {
+	return syncNewVersion(p, l)
+}
+
+func (p *fakePkg) ContainsFile(path string) (alpm.File, error) {
+	for _, file := range p.files {
+		if file.Name == path {
+			return file, nil
+		}
+	}
+	return alpm.File{}, fmt.Errorf("file %s not found", path)
+}
+
+// syncNewVersion mimics alpm_sync_get_new_version: the first sync database
+// that has the package decides, and only a newer version counts.
+func syncNewVersion(pkg alpm.IPackage, l alpm.IDBList) alpm.IPackage {
+	var found alpm.IPackage
+	l.ForEach(func(db alpm.IDB) error {
+		if found == nil {
+			found = db.Pkg(pkg.Name())
+		}
+		return nil
+	})
+	if found == nil || alpm.VerCmp(found.Version(), pkg.Version()) <= 0 {
+		return nil
+	}
+	return found
+}
+
+type fakeDB struct {
+	alpm.IDB
+	name string
+	pkgs []*fakePkg
+}
+
+// newFakeDB returns a database holding the given packages.
+func newFakeDB(name string, pkgs ...*fakePkg) *fakeDB {
+	db := &fakeDB{name: name, pkgs: pkgs}
+	for _, pkg := range pkgs {
+		pkg.db = db
+	}
+	return db
+}
+
+func (db *fakeDB) Name() string { return db.name }
+
+func (db *fakeDB) Pkg(name string) alpm.IPackage {
+	for _, pkg := range db.pkgs {
+		if pkg.name == name {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// dependents returns the packages of db that depend on pkg by name or
+// through one of its provides, ignoring versions.
+func (db *fakeDB) dependents(pkg *fakePkg, optional bool) []string {
+	var names []string
+	for _, other := range db.pkgs {
+		deps := other.depends
+		if optional {
+			deps = other.optDepends
+		}
+		for _, dep := range deps {
+			if dep.Name == pkg.name || fakeDepList(pkg.provides).has(dep.Name) {
+				names = append(names, other.name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func (db *fakeDB) PkgCache() alpm.IPackageList {
+	list := make(fakePkgList, len(db.pkgs))
+	for i, pkg := range db.pkgs {
+		list[i] = pkg
+	}
+	return list
+}
+
+type fakePkgList []alpm.IPackage
+
+func (l fakePkgList) ForEach(f func(alpm.IPackage) error) error {
+	for _, pkg := range l {
+		if err := f(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l fakePkgList) Slice() []alpm.IPackage        { return l }
+func (l fakePkgList) SortBySize() alpm.IPackageList { return l }
+
+// FindSatisfier returns the first package named like the dependency or
+// providing it. Versions are ignored.
+func (l fakePkgList) FindSatisfier(dep string) (alpm.IPackage, error) {
+	if i := strings.IndexAny(dep, "<>="); i >= 0 {
+		dep = dep[:i]
+	}
+	for _, pkg := range l {
+		if pkg.Name() == dep || fakeDepList(pkg.Provides().Slice()).has(dep) {
+			return pkg, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeDBList []alpm.IDB
+
+func (l fakeDBList) ForEach(f func(alpm.IDB) error) error {
+	for _, db := range l {
+		if err := f(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l fakeDBList) Slice() []alpm.IDB                      { return l }
+func (l fakeDBList) Append(alpm.IDB)                        {}
+func (l fakeDBList) FindGroupPkgs(string) alpm.IPackageList { return fakePkgList{} }
+
+func (l fakeDBList) FindSatisfier(dep string) (alpm.IPackage, error) {
+	for _, db := range l {
+		if pkg, _ := db.PkgCache().FindSatisfier(dep); pkg != nil {
+			return pkg, nil
+		}
+	}
+	return nil, nil
+}
+
+type fakeDepList []alpm.Depend
+
+func (l fakeDepList) ForEach(f func(*alpm.Depend) error) error {
+	for i := range l {
+		if err := f(&l[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l fakeDepList) Slice() []alpm.Depend { return l }
+
+func (l fakeDepList) has(name string) bool {
+	for _, dep := range l {
+		if dep.Name == name {
+			return true
+		}
+	}
+	return false
+}
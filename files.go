@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// PackageFile is a file installed by a package.
+type PackageFile struct {
+	// Path is the absolute path; directories end with a slash.
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"isDir"`
+	// Backup is set for files pacman keeps as .pacnew/.pacsave, usually
+	// configuration files.
+	Backup bool `json:"backup"`
+}
+
+// FileOwner is the result of FindOwner.
+type FileOwner struct {
+	Path string `json:"path"`
+	// ResolvedPath is the path with the symlinks of its parent directories
+	// resolved, e.g. /usr/bin/ls for /bin/ls, which is what package file
+	// lists contain.
+	ResolvedPath string `json:"resolvedPath"`
+	IsDir        bool   `json:"isDir"`
+	// Owners are the packages owning the path. Directories are usually
+	// owned by several packages.
+	Owners []string `json:"owners"`
+	// Target and TargetOwners are set when the path is a symlink; the link
+	// itself and the file it points to may belong to different packages.
+	Target       string   `json:"target,omitempty"`
+	TargetOwners []string `json:"targetOwners,omitempty"`
+}
+
+// GetPackageFiles returns the files installed by a package, like pacman -Ql.
+func (a *App) GetPackageFiles(name string) ([]PackageFile, error) {
+	h, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+	pkg := localDB.Pkg(name)
+	if pkg == nil {
+		return nil, fmt.Errorf("package %s is not installed", name)
+	}
+
+	backup := make(map[string]bool)
+	pkg.Backup().ForEach(func(file alpm.BackupFile) error {
+		backup[file.Name] = true
+		return nil
+	})
+
+	files := pkg.Files()
+	result := make([]PackageFile, 0, len(files))
+	for _, file := range files {
+		result = append(result, PackageFile{
+			Path:   "/" + file.Name,
+			Size:   file.Size,
+			IsDir:  strings.HasSuffix(file.Name, "/"),
+			Backup: backup[file.Name],
+		})
+	}
+	return result, nil
+}
+
+// FindOwner returns the packages owning a file or directory, like pacman -Qo.
+func (a *App) FindOwner(path string) (*FileOwner, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// Package file lists do not go through symlinked directories, so resolve
+	// the parent but keep the last component, which may itself be a link.
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	result := &FileOwner{
+		Path:         path,
+		ResolvedPath: filepath.Join(dir, filepath.Base(path)),
+		IsDir:        fi.IsDir(),
+	}
+
+	h, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	defer h.Release()
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+	packages := localDB.PkgCache().Slice()
+
+	result.Owners = fileOwners(packages, result.ResolvedPath, result.IsDir)
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			tfi, err := os.Stat(target)
+			if err == nil {
+				result.Target = target
+				result.TargetOwners = fileOwners(packages, target, tfi.IsDir())
+			}
+		}
+	}
+
+	if len(result.Owners) == 0 && len(result.TargetOwners) == 0 {
+		return nil, fmt.Errorf("no package owns %s", path)
+	}
+	return result, nil
+}
+
+// fileOwners returns the packages whose file list contains path.
+func fileOwners(packages []alpm.IPackage, path string, isDir bool) []string {
+	key := strings.TrimPrefix(path, "/")
+	if isDir && key != "" {
+		key += "/"
+	}
+	var owners []string
+	for _, pkg := range packages {
+		if _, err := pkg.ContainsFile(key); err == nil {
+			owners = append(owners, pkg.Name())
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
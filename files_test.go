@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+func TestFileOwners(t *testing.T) {
+	files := func(names ...string) []alpm.File {
+		var files []alpm.File
+		for _, name := range names {
+			files = append(files, alpm.File{Name: name})
+		}
+		return files
+	}
+	packages := []alpm.IPackage{
+		&fakePkg{name: "filesystem", files: files("usr/", "usr/bin/", "usr/share/")},
+		&fakePkg{name: "coreutils", files: files("usr/", "usr/bin/", "usr/bin/ls")},
+		&fakePkg{name: "bash", files: files("usr/", "usr/bin/", "usr/bin/bash", "usr/bin/sh")},
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  []string
+	}{
+		{"/usr/bin/ls", false, []string{"coreutils"}},
+		{"/usr/bin/sh", false, []string{"bash"}},
+		{"/usr/bin", true, []string{"bash", "coreutils", "filesystem"}},
+		{"/usr/share", true, []string{"filesystem"}},
+		// Without the trailing slash a directory is not found
+		{"/usr/bin", false, nil},
+		{"/usr/bin/zsh", false, nil},
+	}
+	for _, tt := range tests {
+		if got := fileOwners(packages, tt.path, tt.isDir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fileOwners(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// maxFileSearchResults bounds the packages returned by SearchFiles, as short
+// queries can match a large part of the repositories.
+const maxFileSearchResults = 500
+
+// FileSearchResult is a repository package containing files that match a
+// SearchFiles query.
+type FileSearchResult struct {
+	Package   PackageInfo `json:"package"`
+	Installed bool        `json:"installed"`
+	// Outdated is set when the installed version is older than the
+	// repository one, whose file list may differ.
+	Outdated bool `json:"outdated,omitempty"`
+	// Files are the matching paths, absolute.
+	Files []string `json:"files"`
+}
+
+// SearchFiles searches the sync files databases for packages containing a
+// file, like pacman -F. A query without a slash matches file names, e.g.
+// "libfoo.so.3"; a query with a slash matches the end of the path, e.g.
+// "bin/rg" or "/usr/bin/rg". Directories are not matched.
+//
+// The files databases are separate from the package databases and have to be
+// downloaded with RefreshFilesDatabases first.
+func (a *App) SearchFiles(query string) ([]FileSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(pacmanDBPath, "sync", "*.files")); len(matches) == 0 {
+		return nil, fmt.Errorf("the files databases have not been downloaded yet")
+	}
+
+	h, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	defer h.Release()
+
+	// Registering with the .files extension gives packages with file lists
+	if err := h.SetDBExt(".files"); err != nil {
+		return nil, fmt.Errorf("failed to select files databases: %v", err)
+	}
+	syncDBs, err := registerConfRepos(h)
+	if err != nil {
+		return nil, err
+	}
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+
+	var results []FileSearchResult
+	for _, db := range syncDBs {
+		for _, pkg := range db.PkgCache().Slice() {
+			files := matchFiles(pkg.Files(), query)
+			if len(files) == 0 {
+				continue
+			}
+
+			local := localDB.Pkg(pkg.Name())
+			results = append(results, FileSearchResult{
+				Package:   syncPackageInfo(db.Name(), pkg),
+				Installed: local != nil,
+				Outdated:  local != nil && alpm.VerCmp(local.Version(), pkg.Version()) < 0,
+				Files:     files,
+			})
+			if len(results) >= maxFileSearchResults {
+				return results, nil
+			}
+		}
+	}
+	return results, nil
+}
+
+// matchFiles returns the absolute paths of the files of a package file list
+// that match a SearchFiles query.
+func matchFiles(files []alpm.File, query string) []string {
+	matchPath := strings.Contains(query, "/")
+	suffix := "/" + strings.TrimPrefix(query, "/")
+
+	var matches []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+		name := "/" + file.Name
+		if (matchPath && strings.HasSuffix(name, suffix)) || (!matchPath && path.Base(name) == query) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// RefreshFilesDatabases downloads the sync files databases, like pacman -Fy.
+// Progress is emitted as "refresh:progress" events.
+func (a *App) RefreshFilesDatabases() error {
+	if err := a.pacmanSync("-Fy"); err != nil {
+		return fmt.Errorf("failed to refresh files databases: %w", err)
+	}
+	recordRefresh(filesRefreshStamp)
+	a.emit("refresh:progress", RefreshProgress{Status: "done"})
+	return nil
+}
+
+// GetLastFilesRefresh returns when the application last refreshed the files
+// databases as a Unix timestamp, or 0 if it never did.
+func (a *App) GetLastFilesRefresh() int64 {
+	return lastRefresh(filesRefreshStamp)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+func TestMatchFiles(t *testing.T) {
+	// File lists as stored in the files databases: relative, directories
+	// with a trailing slash
+	var files []alpm.File
+	for _, name := range []string{
+		"usr/",
+		"usr/bin/",
+		"usr/bin/rg",
+		"usr/lib/",
+		"usr/lib/libfoo.so.3",
+		"usr/lib/libfoo.so.3.1",
+		"usr/share/doc/ripgrep/rg",
+		"usr/share/fish/vendor_completions.d/rg.fish",
+	} {
+		files = append(files, alpm.File{Name: name})
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"rg", []string{"/usr/bin/rg", "/usr/share/doc/ripgrep/rg"}},
+		{"libfoo.so.3", []string{"/usr/lib/libfoo.so.3"}},
+		{"bin/rg", []string{"/usr/bin/rg"}},
+		{"/usr/bin/rg", []string{"/usr/bin/rg"}},
+		// A path suffix matches whole components only
+		{"in/rg", nil},
+		// Directories are not matched
+		{"bin", nil},
+		{"usr/bin/", nil},
+		{"rg.f", nil},
+	}
+	for _, tt := range tests {
+		if got := matchFiles(files, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchFiles(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-import React, { useState } from "react";
+import React, { useEffect, useState } from "react";
 import { ThemeProvider } from "./components/ui/theme-provider";
 import Navbar from "./components/Navbar";
 import Home from "./components/Home";
@@ -6,11 +6,18 @@ import Search from "./components/Search";
 import Install from "./components/Installed";
 import { Toaster } from "./components/ui/toaster";
 import Updates from "./components/Updates";
+import { EventsOn } from "../wailsjs/runtime/runtime";
 
 type PageType = "home" | "search" | "install" | "updates";
 
 const App: React.FC = () => {
   const [currentPage, setCurrentPage] = useState<PageType>("home");
+
+  // The backend asks to switch pages, e.g. when an update notification is clicked
+  useEffect(() => {
+    return EventsOn("navigate", (page: PageType) => setCurrentPage(page));
+  }, []);
+
   const renderPage = () => {
     switch (currentPage) {
       case "home":
@@ -21,6 +21,7 @@ import { ScrollArea } from "@/components/ui/scroll-area";
 import { main } from "wailsjs/go/models";
 import {
   CheckPackageInstalled,
+  CheckUninstall,
   Install,
   Uninstall,
 } from "../../wailsjs/go/main/App";
@@ -45,6 +46,8 @@ const PackageDetails: React.FC<PackageDetailsProps> = ({
   const [isInstalling, setIsInstalling] = useState<boolean>(false);
   const [installProgress, setInstallProgress] = useState<number>(0);
   const [error, setError] = useState<string | null>(null);
+  const [uninstallCheck, setUninstallCheck] =
+    useState<main.UninstallCheck | null>(null);
   const [isLoading, setIsLoading] = useState<boolean>(true);
   const installInstructionsRef = useRef<HTMLDivElement>(null);
   const [copiedCommand, setCopiedCommand] = useState<string | null>(null);
@@ -79,18 +82,16 @@ const PackageDetails: React.FC<PackageDetailsProps> = ({
   const handleInstall = useCallback(async () => {
     if (!app?.name) return;
 
-    try {
-      setIsInstalling(true);
-      setInstallProgress(0);
-      setError(null);
-
-      const installationInterval = setInterval(() => {
-        setInstallProgress((prev) => Math.min(prev + 10, 90));
-      }, 500);
+    setIsInstalling(true);
+    setInstallProgress(0);
+    setError(null);
+    const installationInterval = setInterval(() => {
+      setInstallProgress((prev) => Math.min(prev + 10, 90));
+    }, 500);
 
+    try {
       await Install(app.name);
 
-      clearInterval(installationInterval);
       setInstallProgress(100);
       await checkIfInstalled(app.name);
       onInstallStateChange();
@@ -98,27 +99,43 @@ const PackageDetails: React.FC<PackageDetailsProps> = ({
       setError("Failed to install package");
       console.error("Error installing package:", err);
     } finally {
+      clearInterval(installationInterval);
       const isExist = await CheckPackageInstalled(app.name);
       setIsInstalled(isExist);
       setIsInstalling(false);
     }
   }, [app, onInstallStateChange]);
 
-  const handleUninstall = async () => {
+  // Uninstall skips dependency checks, so ask before breaking other packages
+  const requestUninstall = async () => {
     if (!app?.name) return;
 
     try {
-      setIsInstalling(true);
-      setInstallProgress(0);
-      setError(null);
+      const check = await CheckUninstall(app.name);
+      if (check.requiredBy?.length) {
+        setUninstallCheck(check);
+        return;
+      }
+    } catch (err) {
+      console.error("Error checking reverse dependencies:", err);
+    }
+    await handleUninstall();
+  };
+
+  const handleUninstall = async () => {
+    if (!app?.name) return;
 
-      const installationInterval = setInterval(() => {
-        setInstallProgress((prev) => Math.min(prev + 10, 90));
-      }, 500);
+    setUninstallCheck(null);
+    setIsInstalling(true);
+    setInstallProgress(0);
+    setError(null);
+    const installationInterval = setInterval(() => {
+      setInstallProgress((prev) => Math.min(prev + 10, 90));
+    }, 500);
 
+    try {
       await Uninstall(app.name);
 
-      clearInterval(installationInterval);
       setInstallProgress(100);
       await checkIfInstalled(app.name);
       onInstallStateChange();
@@ -126,6 +143,7 @@ const PackageDetails: React.FC<PackageDetailsProps> = ({
       setError("Failed to install package");
       console.error("Error installing package:", err);
     } finally {
+      clearInterval(installationInterval);
       const isExist = await CheckPackageInstalled(app.name);
       setIsInstalled(isExist);
       setIsInstalling(false);
@@ -149,7 +167,7 @@ const PackageDetails: React.FC<PackageDetailsProps> = ({
   const renderInstallButton = useMemo(() => {
     if (isInstalled) {
       return (
-        <Button className="" onClick={handleUninstall}>
+        <Button className="" onClick={requestUninstall}>
           <Trash2 className="mr-2 h-4 w-4" /> Uninstall
         </Button>
       );
@@ -313,6 +331,36 @@ const PackageDetails: React.FC<PackageDetailsProps> = ({
               <span className="block sm:inline">{error}</span>
             </div>
           )}
+          {uninstallCheck && (
+            <div
+              className="bg-yellow-100 border border-yellow-400 text-yellow-800 px-4 py-3 rounded relative space-y-2"
+              role="alert"
+            >
+              <p>
+                <strong className="font-bold">Warning: </strong>
+                {uninstallCheck.name} is required by{" "}
+                {uninstallCheck.requiredBy.join(", ")}, which will stop
+                working if it is removed.
+              </p>
+              {uninstallCheck.optionalFor?.length > 0 && (
+                <p>
+                  It is also optional for{" "}
+                  {uninstallCheck.optionalFor.join(", ")}.
+                </p>
+              )}
+              <div className="flex gap-2">
+                <Button variant="destructive" onClick={handleUninstall}>
+                  Remove anyway
+                </Button>
+                <Button
+                  variant="outline"
+                  onClick={() => setUninstallCheck(null)}
+                >
+                  Cancel
+                </Button>
+              </div>
+            </div>
+          )}
           <Card>
             <CardHeader>
               <div className="flex justify-between items-start">
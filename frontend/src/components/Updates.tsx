@@ -12,7 +12,8 @@ import {
   CardTitle,
 } from "@/components/ui/card";
 import {
-  GetAvailableUpdates,
+  AcknowledgeNews,
+  CheckUpdates,
   HumanReadableSize,
   UpdateAllPkg,
   UpdateSinglePkg,
@@ -27,6 +28,7 @@ const useAvailableUpdates = () => {
   const [availableUpdates, setAvailableUpdates] = useState<main.UpdateInfo[]>(
     []
   );
+  const [news, setNews] = useState<main.NewsItem[]>([]);
   const [isLoading, setIsLoading] = useState<boolean>(true);
   const [error, setError] = useState<string | null>(null);
   const [totalDownloadSize, setTotalDownloadSize] = useState<string>("");
@@ -34,8 +36,10 @@ const useAvailableUpdates = () => {
   const fetchAvailableUpdates = useCallback(async () => {
     setIsLoading(true);
     try {
-      const updates = await GetAvailableUpdates();
+      const report = await CheckUpdates();
+      const updates = report.updates || [];
       setAvailableUpdates(updates);
+      setNews(report.news || []);
       const totalSize = updates.reduce(
         (acc, update) => acc + update.downloadSize,
         0
@@ -59,6 +63,7 @@ const useAvailableUpdates = () => {
 
   return {
     availableUpdates,
+    news,
     isLoading,
     error,
     totalDownloadSize,
@@ -88,6 +93,7 @@ const useReadableSizes = (availableUpdates: main.UpdateInfo[]) => {
 const Updates: React.FC = () => {
   const {
     availableUpdates,
+    news,
     isLoading,
     error,
     totalDownloadSize,
@@ -96,6 +102,7 @@ const Updates: React.FC = () => {
   const readableSizes = useReadableSizes(availableUpdates);
   const [searchTerm, setSearchTerm] = useState<string>("");
   const [updatingAll, setUpdatingAll] = useState<boolean>(false);
+  const [acknowledging, setAcknowledging] = useState<boolean>(false);
   const [updatingPackages, setUpdatingPackages] = useState<Set<string>>(
     new Set()
   );
@@ -134,6 +141,19 @@ const Updates: React.FC = () => {
     }
   };
 
+  // Unread news must be acknowledged before the system can be upgraded
+  const handleAcknowledgeNews = async () => {
+    setAcknowledging(true);
+    try {
+      await AcknowledgeNews(news.map((item) => item.link));
+    } catch (err) {
+      console.error("Error acknowledging news:", err);
+    } finally {
+      setAcknowledging(false);
+      fetchAvailableUpdates();
+    }
+  };
+
   const UpdateItem = useCallback(
     ({
       columnIndex,
@@ -278,13 +298,44 @@ const Updates: React.FC = () => {
         />
         <Button
           onClick={handleAllPackageUpdate}
-          disabled={updatingAll || availableUpdates.length === 0}
+          disabled={
+            updatingAll || availableUpdates.length === 0 || news.length > 0
+          }
           aria-label="Update all packages"
         >
           <ArrowUpCircle className="h-5 w-5 mr-2" />
           {updatingAll ? "Updating..." : "Update All"}
         </Button>
       </div>
+      {news.length > 0 && (
+        <Alert className="ml-3 mr-3 w-auto">
+          <AlertCircle className="h-4 w-4" />
+          <AlertTitle>Read the Arch news before updating</AlertTitle>
+          <AlertDescription>
+            <ul className="list-disc pl-5 py-2">
+              {news.map((item) => (
+                <li key={item.link}>
+                  <a
+                    href={item.link}
+                    target="_blank"
+                    rel="noreferrer"
+                    className="underline"
+                  >
+                    {item.title}
+                  </a>
+                </li>
+              ))}
+            </ul>
+            <Button
+              variant="outline"
+              onClick={handleAcknowledgeNews}
+              disabled={acknowledging}
+            >
+              I have read the news
+            </Button>
+          </AlertDescription>
+        </Alert>
+      )}
       {totalDownloadSize && (
         <p className="text-sm text-muted-foreground pl-3">
           Total download size: {totalDownloadSize}
@@ -2,8 +2,14 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function AcknowledgeNews(arg1:Array<string>):Promise<void>;
+
 export function CheckPackageInstalled(arg1:string):Promise<boolean>;
 
+export function CheckUninstall(arg1:string):Promise<main.UninstallCheck>;
+
+export function CheckUpdates():Promise<main.UpdateReport>;
+
 export function GetAvailableUpdates():Promise<Array<main.UpdateInfo>>;
 
 export function GetInstalledPackages():Promise<Array<main.PackageInfo>>;
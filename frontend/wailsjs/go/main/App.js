@@ -2,10 +2,22 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AcknowledgeNews(arg1) {
+  return window['go']['main']['App']['AcknowledgeNews'](arg1);
+}
+
 export function CheckPackageInstalled(arg1) {
   return window['go']['main']['App']['CheckPackageInstalled'](arg1);
 }
 
+export function CheckUninstall(arg1) {
+  return window['go']['main']['App']['CheckUninstall'](arg1);
+}
+
+export function CheckUpdates() {
+  return window['go']['main']['App']['CheckUpdates']();
+}
+
 export function GetAvailableUpdates() {
   return window['go']['main']['App']['GetAvailableUpdates']();
 }
@@ -1,5 +1,25 @@
 export namespace main {
 	
+	export class NewsItem {
+	    title: string;
+	    link: string;
+	    published: number;
+	    summary: string;
+	    read: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewsItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.title = source["title"];
+	        this.link = source["link"];
+	        this.published = source["published"];
+	        this.summary = source["summary"];
+	        this.read = source["read"];
+	    }
+	}
 	export class PackageInfo {
 	    name: string;
 	    version: string;
@@ -26,6 +46,38 @@ export namespace main {
 	        this.lastupdated = source["lastupdated"];
 	    }
 	}
+	export class UninstallCheck {
+	    name: string;
+	    requiredBy: string[];
+	    optionalFor: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new UninstallCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.requiredBy = source["requiredBy"];
+	        this.optionalFor = source["optionalFor"];
+	    }
+	}
+	export class RepoSummary {
+	    count: number;
+	    download: number;
+	    sizeChange: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RepoSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.count = source["count"];
+	        this.download = source["download"];
+	        this.sizeChange = source["sizeChange"];
+	    }
+	}
 	export class UpdateInfo {
 	    name: string;
 	    oldVersion: string;
@@ -46,6 +98,80 @@ export namespace main {
 	        this.downloadSize = source["downloadSize"];
 	    }
 	}
+	export class UpdateSummary {
+	    count: number;
+	    ignored: number;
+	    unknownSize: number;
+	    totalDownload: number;
+	    totalSizeChange: number;
+	    byRepository: {[key: string]: RepoSummary};
+	
+	    static createFrom(source: any = {}) {
+	        return new UpdateSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.count = source["count"];
+	        this.ignored = source["ignored"];
+	        this.unknownSize = source["unknownSize"];
+	        this.totalDownload = source["totalDownload"];
+	        this.totalSizeChange = source["totalSizeChange"];
+	        this.byRepository = this.convertValues(source["byRepository"], RepoSummary, true);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class UpdateReport {
+	    updates: UpdateInfo[];
+	    summary: UpdateSummary;
+	    news: NewsItem[];
+	
+	    static createFrom(source: any = {}) {
+	        return new UpdateReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.updates = this.convertValues(source["updates"], UpdateInfo);
+	        this.summary = this.convertValues(source["summary"], UpdateSummary);
+	        this.news = this.convertValues(source["news"], NewsItem);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
 
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+const (
+	operationsFile = "operations.json"
+	// maxOperations bounds the operation journal; older entries are dropped.
+	maxOperations = 1000
+
+	transactionSourceApp      = "apm"
+	transactionSourceExternal = "external"
+)
+
+// Transaction is a pacman transaction read from pacman.log.
+type Transaction struct {
+	Started  int64 `json:"started"`
+	Finished int64 `json:"finished,omitempty"`
+	// Command is the pacman command line that ran the transaction, if logged.
+	Command string `json:"command,omitempty"`
+	// Status is completed, failed, interrupted or incomplete when the log
+	// ends before the transaction does.
+	Status  string          `json:"status"`
+	Actions []PackageAction `json:"actions"`
+	// Source is "apm" for transactions started by this application and
+	// "external" for pacman, yay and other tools. A transaction is only
+	// attributed to the application when it ran while one of its commands did
+	// and changed one of the packages the command named; commands without
+	// package arguments, such as full upgrades, are matched by time alone.
+	Source string `json:"source"`
+}
+
+// PackageAction is a package change within a transaction.
+type PackageAction struct {
+	// Action is installed, upgraded, downgraded, reinstalled or removed.
+	Action     string `json:"action"`
+	Name       string `json:"name"`
+	OldVersion string `json:"oldVersion,omitempty"`
+	NewVersion string `json:"newVersion,omitempty"`
+}
+
+// HistoryFilter selects transactions. Zero fields match everything.
+type HistoryFilter struct {
+	// Package only keeps transactions that changed this package.
+	Package string `json:"package"`
+	// Since and Until bound the transaction start as Unix timestamps.
+	Since int64 `json:"since"`
+	Until int64 `json:"until"`
+}
+
+// operationRecord is a privileged command run by the application, used to
+// tell its transactions apart from those of other tools.
+type operationRecord struct {
+	Started  int64 `json:"started"`
+	Finished int64 `json:"finished"`
+	// Targets are the packages named on the command line.
+	Targets []string `json:"targets,omitempty"`
+}
+
+var (
+	operationsMu sync.Mutex
+	// pacman.log lines look like "[2024-05-01T10:00:00+0200] [ALPM] message";
+	// logs before pacman 5.1 use "[2018-01-01 10:00] message".
+	logLineRe = regexp.MustCompile(`^\[([^\]]+)\] (?:\[([^\]]+)\] )?(.*)$`)
+	actionRe  = regexp.MustCompile(`^(installed|upgraded|downgraded|reinstalled|removed) (\S+) \((.*)\)$`)
+)
+
+// GetTransactionHistory returns the transactions of pacman.log matching
+// filter, newest first.
+func (a *App) GetTransactionHistory(filter HistoryFilter) ([]Transaction, error) {
+	path := pacmanLogPath()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	operations := readOperations()
+
+	var transactions []Transaction
+	var current *Transaction
+	var command string
+	finish := func(status string, at int64) {
+		current.Status = status
+		current.Finished = at
+		if filter.matches(current) {
+			current.Source = transactionSource(current, operations)
+			transactions = append(transactions, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := logLineRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		at := parseLogTime(m[1])
+		source, message := m[2], m[3]
+
+		switch {
+		case source == "PACMAN" && strings.HasPrefix(message, "Running '"):
+			command = strings.TrimSuffix(strings.TrimPrefix(message, "Running '"), "'")
+		case message == "transaction started":
+			if current != nil {
+				finish("incomplete", 0)
+			}
+			current = &Transaction{Started: at, Command: command}
+			command = ""
+		case current == nil:
+		case message == "transaction completed":
+			finish("completed", at)
+		case message == "transaction failed":
+			finish("failed", at)
+		case message == "transaction interrupted":
+			finish("interrupted", at)
+		case source == "ALPM" || source == "":
+			if action, ok := parseAction(message); ok {
+				current.Actions = append(current.Actions, action)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if current != nil {
+		finish("incomplete", 0)
+	}
+
+	// Newest first
+	for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+		transactions[i], transactions[j] = transactions[j], transactions[i]
+	}
+	return transactions, nil
+}
+
+// pacmanLogPath returns the LogFile of pacman.conf, or the default location.
+func pacmanLogPath() string {
+	conf, _, err := paconf.ParseFile(pacmanConf)
+	if err != nil || conf.LogFile == "" {
+		return pacmanLog
+	}
+	return conf.LogFile
+}
+
+func parseLogTime(s string) int64 {
+	if t, err := time.Parse("2006-01-02T15:04:05-0700", s); err == nil {
+		return t.Unix()
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t.Unix()
+	}
+	return 0
+}
+
+// parseAction parses messages such as "upgraded foo (1.0-1 -> 1.1-1)".
+func parseAction(message string) (PackageAction, bool) {
+	m := actionRe.FindStringSubmatch(message)
+	if m == nil {
+		return PackageAction{}, false
+	}
+	action := PackageAction{Action: m[1], Name: m[2]}
+	if oldVersion, newVersion, ok := strings.Cut(m[3], " -> "); ok {
+		action.OldVersion, action.NewVersion = oldVersion, newVersion
+	} else if action.Action == "removed" {
+		action.OldVersion = m[3]
+	} else {
+		action.NewVersion = m[3]
+	}
+	return action, true
+}
+
+func (f HistoryFilter) matches(t *Transaction) bool {
+	if f.Since != 0 && t.Started < f.Since {
+		return false
+	}
+	if f.Until != 0 && t.Started > f.Until {
+		return false
+	}
+	if f.Package == "" {
+		return true
+	}
+	for _, action := range t.Actions {
+		if action.Name == f.Package {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionSource reports whether a transaction started while the
+// application was running a privileged command that targeted one of the
+// packages the transaction changed.
+func transactionSource(t *Transaction, operations []operationRecord) string {
+	for _, op := range operations {
+		// pacman.log has a resolution of one second
+		if t.Started < op.Started-1 || t.Started > op.Finished+1 {
+			continue
+		}
+		if len(op.Targets) == 0 {
+			return transactionSourceApp
+		}
+		for _, action := range t.Actions {
+			if contains(op.Targets, action.Name) {
+				return transactionSourceApp
+			}
+		}
+	}
+	return transactionSourceExternal
+}
+
+// trackOperation records that the application runs the given privileged
+// command until the returned function is called:
+//
+//	defer trackOperation(args...)()
+func trackOperation(command ...string) func() {
+	started := time.Now().Unix()
+	targets := commandTargets(command)
+	return func() {
+		operationsMu.Lock()
+		defer operationsMu.Unlock()
+
+		operations := readOperations()
+		operations = append(operations, operationRecord{Started: started, Finished: time.Now().Unix(), Targets: targets})
+		if len(operations) > maxOperations {
+			operations = operations[len(operations)-maxOperations:]
+		}
+		if err := writeOperations(operations); err != nil {
+			log.Printf("Error saving operation journal: %v", err)
+		}
+	}
+}
+
+// commandTargets returns the packages named by a pacman or yay command line,
+// taken from the names and package files among its arguments. Shell scripts
+// are not parsed and have no targets.
+func commandTargets(command []string) []string {
+	if len(command) > 0 && command[0] == "pkexec" {
+		command = command[1:]
+	}
+	if len(command) == 0 || command[0] == "sh" {
+		return nil
+	}
+	var targets []string
+	for _, arg := range command[1:] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if file, ok := parsePkgFileName(filepath.Base(arg)); ok {
+			targets = append(targets, file.name)
+			continue
+		}
+		// Strip the repository of "repo/name"
+		if i := strings.LastIndex(arg, "/"); i >= 0 {
+			arg = arg[i+1:]
+		}
+		if pkgNameRe.MatchString(arg) {
+			targets = append(targets, arg)
+		}
+	}
+	return targets
+}
+
+func readOperations() []operationRecord {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, operationsFile))
+	if err != nil {
+		return nil
+	}
+	var operations []operationRecord
+	if err := json.Unmarshal(data, &operations); err != nil {
+		log.Printf("Error reading operation journal: %v", err)
+	}
+	return operations
+}
+
+func writeOperations(operations []operationRecord) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, operationsFile), data, 0o644)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		message string
+		want    PackageAction
+		ok      bool
+	}{
+		{
+			message: "installed vim (9.1.0-1)",
+			want:    PackageAction{Action: "installed", Name: "vim", NewVersion: "9.1.0-1"},
+			ok:      true,
+		},
+		{
+			message: "upgraded linux (6.8.1.arch1-1 -> 6.8.2.arch1-1)",
+			want:    PackageAction{Action: "upgraded", Name: "linux", OldVersion: "6.8.1.arch1-1", NewVersion: "6.8.2.arch1-1"},
+			ok:      true,
+		},
+		{
+			message: "downgraded python-foo (2:1.0-2 -> 2:0.9-1)",
+			want:    PackageAction{Action: "downgraded", Name: "python-foo", OldVersion: "2:1.0-2", NewVersion: "2:0.9-1"},
+			ok:      true,
+		},
+		{
+			message: "reinstalled gtk3 (1:3.24.41-1)",
+			want:    PackageAction{Action: "reinstalled", Name: "gtk3", NewVersion: "1:3.24.41-1"},
+			ok:      true,
+		},
+		{
+			message: "removed libfoo (1.2-3)",
+			want:    PackageAction{Action: "removed", Name: "libfoo", OldVersion: "1.2-3"},
+			ok:      true,
+		},
+		{message: "transaction started"},
+		{message: "warning: /etc/foo installed as /etc/foo.pacnew"},
+		{message: "installed vim"},
+		{message: "upgraded (1.0-1 -> 1.1-1)"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseAction(tt.message)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseAction(%q) = %+v, %v, want %+v, %v", tt.message, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCommandTargets(t *testing.T) {
+	for _, tt := range []struct {
+		command []string
+		want    []string
+	}{
+		{[]string{"pkexec", "yay", "-S", "vim", "--noconfirm"}, []string{"vim"}},
+		{[]string{"yay", "-S", "--needed", "--noconfirm", "core/linux", "linux-headers"}, []string{"linux", "linux-headers"}},
+		{[]string{"pacman", "-U", "--noconfirm", "/var/cache/pacman/pkg/gtk3-1:3.24.41-1-x86_64.pkg.tar.zst"}, []string{"gtk3"}},
+		{[]string{"yay", "-Syu", "--noconfirm", "--ignore", "linux,mesa"}, nil},
+		{[]string{"pkexec", "sh", "-c", "pacman -Rndd $1", "sh", "foo"}, nil},
+	} {
+		if got := commandTargets(tt.command); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandTargets(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestTransactionSource(t *testing.T) {
+	transaction := &Transaction{
+		Started: 1000,
+		Actions: []PackageAction{{Action: "installed", Name: "libfoo"}, {Action: "installed", Name: "foo"}},
+	}
+	tests := []struct {
+		name string
+		op   operationRecord
+		want string
+	}{
+		{"targeted package", operationRecord{Started: 990, Finished: 1010, Targets: []string{"foo"}}, transactionSourceApp},
+		{"other packages", operationRecord{Started: 990, Finished: 1010, Targets: []string{"bar"}}, transactionSourceExternal},
+		{"no targets", operationRecord{Started: 990, Finished: 1010}, transactionSourceApp},
+		{"within a second", operationRecord{Started: 1001, Finished: 1010, Targets: []string{"foo"}}, transactionSourceApp},
+		{"after the command", operationRecord{Started: 900, Finished: 998, Targets: []string{"foo"}}, transactionSourceExternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transactionSource(transaction, []operationRecord{tt.op}); got != tt.want {
+				t.Errorf("transactionSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+// pkgNameRe is the package name charset accepted by makepkg: alphanumerics
+// and @._+-, not starting with a hyphen or a dot.
+var pkgNameRe = regexp.MustCompile(`^[a-zA-Z0-9@_+][a-zA-Z0-9@._+-]*$`)
+
+// updateFilter marks updates according to the IgnorePkg, IgnoreGroup and
+// HoldPkg options of pacman.conf and the session ignore list.
+type updateFilter struct {
+	ignorePkg   []string
+	ignoreGroup []string
+	holdPkg     []string
+	session     map[string]bool
+}
+
+func (a *App) newUpdateFilter(conf *paconf.Config) updateFilter {
+	session := make(map[string]bool)
+	for _, name := range a.GetSessionIgnored() {
+		session[name] = true
+	}
+	return updateFilter{
+		ignorePkg:   conf.IgnorePkg,
+		ignoreGroup: conf.IgnoreGroup,
+		holdPkg:     conf.HoldPkg,
+		session:     session,
+	}
+}
+
+// apply sets the Ignored, IgnoreReason and Held fields of update. groups are
+// the groups of the new package version.
+func (f updateFilter) apply(update *UpdateInfo, groups []string) {
+	switch {
+	case matchAny(f.ignorePkg, update.Name):
+		update.Ignored, update.IgnoreReason = true, "IgnorePkg"
+	case anyMatchAny(f.ignoreGroup, groups):
+		update.Ignored, update.IgnoreReason = true, "IgnoreGroup"
+	case f.session[update.Name]:
+		update.Ignored, update.IgnoreReason = true, "session"
+	}
+	update.Held = matchAny(f.holdPkg, update.Name)
+}
+
+// matchAny reports whether name matches one of the glob patterns, as pacman
+// does for its package lists.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func anyMatchAny(patterns []string, names []string) bool {
+	for _, name := range names {
+		if matchAny(patterns, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreForSession excludes a package from updates until the application is restarted.
+func (a *App) IgnoreForSession(name string) error {
+	if !pkgNameRe.MatchString(name) {
+		return fmt.Errorf("invalid package name %q", name)
+	}
+	a.ignoreMu.Lock()
+	defer a.ignoreMu.Unlock()
+	if a.sessionIgnored == nil {
+		a.sessionIgnored = make(map[string]bool)
+	}
+	a.sessionIgnored[name] = true
+	return nil
+}
+
+// UnignoreForSession removes a package from the session ignore list.
+func (a *App) UnignoreForSession(name string) {
+	a.ignoreMu.Lock()
+	defer a.ignoreMu.Unlock()
+	delete(a.sessionIgnored, name)
+}
+
+// GetSessionIgnored returns the packages ignored for this session, sorted by name.
+func (a *App) GetSessionIgnored() []string {
+	a.ignoreMu.Lock()
+	defer a.ignoreMu.Unlock()
+	names := make([]string, 0, len(a.sessionIgnored))
+	for name := range a.sessionIgnored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateFilterApply(t *testing.T) {
+	filter := updateFilter{
+		ignorePkg:   []string{"linux", "nvidia-*"},
+		ignoreGroup: []string{"gnome"},
+		holdPkg:     []string{"pacman", "glibc"},
+		session:     map[string]bool{"firefox": true, "pacman": true},
+	}
+
+	tests := []struct {
+		name   string
+		groups []string
+		reason string
+		held   bool
+	}{
+		{name: "linux", reason: "IgnorePkg"},
+		{name: "nvidia-utils", reason: "IgnorePkg"},
+		{name: "nvidia", reason: ""},
+		{name: "linux-headers", reason: ""},
+		{name: "mutter", groups: []string{"gnome"}, reason: "IgnoreGroup"},
+		{name: "gtk4", groups: []string{"gnome-extra"}, reason: ""},
+		{name: "firefox", reason: "session"},
+		{name: "pacman", reason: "session", held: true},
+		{name: "glibc", held: true},
+	}
+	for _, tt := range tests {
+		update := UpdateInfo{Name: tt.name}
+		filter.apply(&update, tt.groups)
+		if update.Ignored != (tt.reason != "") || update.IgnoreReason != tt.reason || update.Held != tt.held {
+			t.Errorf("%s: ignored = %v, reason = %q, held = %v; want reason %q, held %v",
+				tt.name, update.Ignored, update.IgnoreReason, update.Held, tt.reason, tt.held)
+		}
+	}
+}
+
+func TestUpdateFilterApplyEmpty(t *testing.T) {
+	update := UpdateInfo{Name: "linux"}
+	updateFilter{}.apply(&update, []string{"base"})
+	if update.Ignored || update.Held {
+		t.Errorf("empty filter marked %+v", update)
+	}
+}
+
+func TestIgnoreForSession(t *testing.T) {
+	a := &App{}
+	for _, name := range []string{"linux", "lib32-glibc", "gtk+", "python3.12", "@scope_pkg", "0ad"} {
+		if err := a.IgnoreForSession(name); err != nil {
+			t.Errorf("IgnoreForSession(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-Syu", ".hidden", "foo bar", "../etc/passwd", "pkg;rm", "ünïcode"} {
+		if err := a.IgnoreForSession(name); err == nil {
+			t.Errorf("IgnoreForSession(%q) accepted an invalid name", name)
+		}
+	}
+
+	a.UnignoreForSession("gtk+")
+	want := "0ad @scope_pkg lib32-glibc linux python3.12"
+	if got := strings.Join(a.GetSessionIgnored(), " "); got != want {
+		t.Errorf("GetSessionIgnored() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+const (
+	indexVersion   = 1
+	indexFile      = "index.gob"
+	aurNamesURL    = "https://aur.archlinux.org/packages.gz"
+	aurNamesMaxAge = 24 * time.Hour
+	// staleCheckInterval is how often searches look for changed sync
+	// databases. Refreshes done by the application are noticed right away.
+	staleCheckInterval = time.Minute
+)
+
+// indexEntry is a single sync repository package as stored in the search index.
+type indexEntry struct {
+	Name        string
+	Version     string
+	Description string
+	Repository  string
+	Packager    string
+	URL         string
+	Provides    []string
+	Depends     []string
+	BuildDate   int64
+
+	lowerName string
+	lowerDesc string
+}
+
+// searchIndex is the persisted search index. It is rebuilt whenever the
+// modification time of one of the sync databases changes.
+type searchIndex struct {
+	Version    int
+	DBMtimes   map[string]int64
+	Entries    []indexEntry
+	AURNames   []string
+	AURFetched int64
+}
+
+var (
+	pkgIndex   *searchIndex
+	indexMu    sync.RWMutex
+	rebuilding sync.Mutex
+
+	// lastStaleCheck is when currentIndex last compared the databases, in
+	// Unix nanoseconds. Zero forces a check.
+	lastStaleCheck atomic.Int64
+)
+
+// cacheDir returns the per-user cache directory of the application, creating it if needed.
+func cacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache dir: %w", err)
+	}
+	dir := filepath.Join(base, "apm")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return dir, nil
+}
+
+// loadIndex loads the index from disk and rebuilds it in the background when
+// it is missing or stale.
+func loadIndex() {
+	idx, err := readIndex()
+	if err != nil {
+		log.Printf("Search index unavailable, rebuilding: %v", err)
+	} else {
+		idx.prepare()
+		indexMu.Lock()
+		pkgIndex = idx
+		indexMu.Unlock()
+	}
+
+	if idx == nil || idx.stale() || idx.aurStale() {
+		go rebuildIndex(idx)
+	}
+}
+
+func readIndex() (*searchIndex, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var idx searchIndex
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to decode search index: %w", err)
+	}
+	if idx.Version != indexVersion {
+		return nil, fmt.Errorf("search index version %d is outdated", idx.Version)
+	}
+	return &idx, nil
+}
+
+func writeIndex(idx *searchIndex) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, indexFile+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := gob.NewEncoder(w).Encode(idx); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode search index: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, indexFile))
+}
+
+// rebuildIndex walks the sync databases and refreshes the AUR name dump if
+// needed, then atomically swaps in and persists the new index. prev may be nil.
+func rebuildIndex(prev *searchIndex) {
+	if !rebuilding.TryLock() {
+		return
+	}
+	defer rebuilding.Unlock()
+
+	start := time.Now()
+	idx := &searchIndex{
+		Version:  indexVersion,
+		DBMtimes: syncDBMtimes(),
+	}
+
+	if prev != nil && !prev.stale() {
+		idx.Entries = prev.Entries
+	} else {
+		// The global handle caches package lists in memory, so read the
+		// databases through a fresh handle to pick up on-disk changes.
+		handle, err := alpm.Initialize("/", "/var/lib/pacman")
+		if err != nil {
+			log.Printf("Error rebuilding search index: failed to initialize alpm: %v", err)
+			return
+		}
+		defer handle.Release()
+
+		for _, name := range syncDBNames() {
+			db, err := handle.RegisterSyncDB(name, 0)
+			if err != nil {
+				log.Printf("Error rebuilding search index: failed to register %s: %v", name, err)
+				continue
+			}
+			repo := db.Name()
+			db.PkgCache().ForEach(func(pkg alpm.IPackage) error {
+				idx.Entries = append(idx.Entries, indexEntry{
+					Name:        pkg.Name(),
+					Version:     pkg.Version(),
+					Description: pkg.Description(),
+					Repository:  repo,
+					Packager:    pkg.Packager(),
+					URL:         pkg.URL(),
+					Provides:    convertDependList(pkg.Provides()),
+					Depends:     convertDependList(pkg.Depends()),
+					BuildDate:   pkg.BuildDate().Unix(),
+				})
+				return nil
+			})
+		}
+		idx.prepare()
+	}
+
+	if prev != nil && !prev.aurStale() {
+		idx.AURNames, idx.AURFetched = prev.AURNames, prev.AURFetched
+	} else if names, err := fetchAURNames(); err != nil {
+		log.Printf("Error fetching AUR package names: %v", err)
+		if prev != nil {
+			idx.AURNames, idx.AURFetched = prev.AURNames, prev.AURFetched
+		}
+	} else {
+		idx.AURNames, idx.AURFetched = names, time.Now().Unix()
+	}
+
+	indexMu.Lock()
+	pkgIndex = idx
+	indexMu.Unlock()
+
+	if err := writeIndex(idx); err != nil {
+		log.Printf("Error saving search index: %v", err)
+	}
+	log.Printf("Search index built in %v (%d packages, %d AUR names)", time.Since(start), len(idx.Entries), len(idx.AURNames))
+}
+
+// syncDBMtimes returns the modification time of every registered sync database file.
+func syncDBMtimes() map[string]int64 {
+	handleMu.RLock()
+	defer handleMu.RUnlock()
+
+	mtimes := make(map[string]int64, len(dbs))
+	if h == nil {
+		return mtimes
+	}
+	dbPath, err := h.DBPath()
+	if err != nil {
+		return mtimes
+	}
+	for _, db := range dbs {
+		fi, err := os.Stat(filepath.Join(dbPath, "sync", db.Name()+".db"))
+		if err != nil {
+			continue
+		}
+		mtimes[db.Name()] = fi.ModTime().UnixNano()
+	}
+	return mtimes
+}
+
+func fetchAURNames() ([]string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(aurNamesURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// The server usually sets Content-Encoding: gzip which net/http undoes
+	// transparently, so only gunzip when the body still is compressed.
+	r := bufio.NewReader(resp.Body)
+	var scanner *bufio.Scanner
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		scanner = bufio.NewScanner(gz)
+	} else {
+		scanner = bufio.NewScanner(r)
+	}
+
+	var names []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
+
+// prepare fills in the lowercase fields used for matching.
+func (idx *searchIndex) prepare() {
+	for i := range idx.Entries {
+		idx.Entries[i].lowerName = strings.ToLower(idx.Entries[i].Name)
+		idx.Entries[i].lowerDesc = strings.ToLower(idx.Entries[i].Description)
+	}
+}
+
+// stale reports whether any sync database changed since the index was built.
+func (idx *searchIndex) stale() bool {
+	current := syncDBMtimes()
+	if len(current) != len(idx.DBMtimes) {
+		return true
+	}
+	for name, mtime := range current {
+		if idx.DBMtimes[name] != mtime {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *searchIndex) aurStale() bool {
+	return time.Since(time.Unix(idx.AURFetched, 0)) > aurNamesMaxAge
+}
+
+// search returns the sync repository packages whose name, provides or
+// description contain query, ranked by how well they match.
+func (idx *searchIndex) search(query string) []PackageInfo {
+	query = strings.ToLower(query)
+	var ranks [matchDescription + 1][]PackageInfo
+	for i := range idx.Entries {
+		e := &idx.Entries[i]
+		if rank, ok := e.match(query); ok {
+			ranks[rank] = append(ranks[rank], e.packageInfo())
+		}
+	}
+	var results []PackageInfo
+	for _, matches := range ranks {
+		results = append(results, matches...)
+	}
+	return results
+}
+
+// Ranks of search matches, best first.
+const (
+	matchExact = iota
+	matchPrefix
+	matchName
+	matchProvides
+	matchDescription
+)
+
+// match reports whether the entry matches the lowercase query and how well.
+// Name matches rank above provides and description matches.
+func (e *indexEntry) match(query string) (int, bool) {
+	switch {
+	case e.lowerName == query:
+		return matchExact, true
+	case strings.HasPrefix(e.lowerName, query):
+		return matchPrefix, true
+	case strings.Contains(e.lowerName, query):
+		return matchName, true
+	case providesMatch(e.Provides, query):
+		return matchProvides, true
+	case strings.Contains(e.lowerDesc, query):
+		return matchDescription, true
+	}
+	return 0, false
+}
+
+// searchAURNames returns the AUR package names from the dump that contain query.
+func (idx *searchIndex) searchAURNames(query string) []string {
+	query = strings.ToLower(query)
+	var names []string
+	for _, name := range idx.AURNames {
+		if strings.Contains(strings.ToLower(name), query) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func providesMatch(provides []string, query string) bool {
+	for _, p := range provides {
+		if strings.Contains(strings.ToLower(p), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *indexEntry) packageInfo() PackageInfo {
+	return PackageInfo{
+		Name:        e.Name,
+		Version:     e.Version,
+		Description: e.Description,
+		Repository:  e.Repository,
+		Maintainer:  e.Packager,
+		UpstreamURL: e.URL,
+		DependList:  e.Depends,
+		LastUpdated: time.Unix(e.BuildDate, 0).UTC().Format("Jan. 2, 2006, 3 p.m. MST"),
+	}
+}
+
+// currentIndex returns the loaded index, scheduling a rebuild when the sync
+// databases changed underneath it. It returns nil until an index is available.
+func currentIndex() *searchIndex {
+	indexMu.RLock()
+	idx := pkgIndex
+	indexMu.RUnlock()
+	if idx != nil && staleCheckDue() && idx.stale() {
+		go rebuildIndex(idx)
+	}
+	return idx
+}
+
+// staleCheckDue reports whether staleCheckInterval passed since the last
+// check for changed databases, and if so starts a new interval.
+func staleCheckDue() bool {
+	now := time.Now().UnixNano()
+	last := lastStaleCheck.Load()
+	if last != 0 && now-last < int64(staleCheckInterval) {
+		return false
+	}
+	return lastStaleCheck.CompareAndSwap(last, now)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchIndexRanking(t *testing.T) {
+	idx := &searchIndex{Entries: []indexEntry{
+		{Name: "python-vim", Description: "Python bindings"},
+		{Name: "neovim", Description: "Fork of Vim aiming to improve user experience", Provides: []string{"vim-plugin-runtime"}},
+		{Name: "gvim", Description: "Vi Improved, a highly configurable text editor", Provides: []string{"vim=9.1"}},
+		{Name: "vim", Description: "Vi Improved, a highly configurable text editor"},
+		{Name: "vim-airline", Description: "Lean & mean status/tabline for vim"},
+		{Name: "kakoune", Description: "Multiple-selection, UNIX-flavored modal editor inspired by vim"},
+		{Name: "vi-improved-bin", Description: "Prebuilt editor", Provides: []string{"vim"}},
+		{Name: "emacs", Description: "The extensible, customizable, self-documenting real-time display editor"},
+	}}
+	idx.prepare()
+
+	var names []string
+	for _, pkg := range idx.search("VIM") {
+		names = append(names, pkg.Name)
+	}
+	want := []string{"vim", "vim-airline", "python-vim", "neovim", "gvim", "vi-improved-bin", "kakoune"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("search(VIM) = %v, want %v", names, want)
+	}
+}
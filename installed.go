@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+const (
+	installReasonExplicit   = "explicit"
+	installReasonDependency = "dependency"
+
+	// Repository values of installed packages that are not in a sync repository
+	repoAUR     = "AUR"
+	repoForeign = "foreign"
+)
+
+// InstalledFilter selects installed packages. Empty fields match everything;
+// set fields must all match.
+type InstalledFilter struct {
+	// Explicit and Deps select by install reason, like pacman -Qe and -Qd.
+	Explicit bool `json:"explicit"`
+	Deps     bool `json:"deps"`
+	// Foreign selects packages not found in any sync repository (-Qm),
+	// Native the ones that are (-Qn).
+	Foreign bool `json:"foreign"`
+	Native  bool `json:"native"`
+	// Repository selects packages from one sync repository, or "AUR" or
+	// "foreign".
+	Repository string `json:"repository"`
+}
+
+// GetInstalledPackagesFiltered returns the installed packages matching filter.
+func (a *App) GetInstalledPackagesFiltered(filter InstalledFilter) ([]PackageInfo, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	h, syncDBs, err := openSystemDB()
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+
+	aurNames := indexAURNames()
+	var packages []PackageInfo
+	for _, pkg := range localDB.PkgCache().Slice() {
+		info := installedPackageInfo(pkg, originRepo(pkg.Name(), syncDBs, aurNames))
+		if filter.matches(info) {
+			packages = append(packages, info)
+		}
+	}
+	return packages, nil
+}
+
+func installedPackageInfo(pkg alpm.IPackage, repo string) PackageInfo {
+	reason := installReasonExplicit
+	if pkg.Reason() == alpm.PkgReasonDepend {
+		reason = installReasonDependency
+	}
+	return PackageInfo{
+		Name:          pkg.Name(),
+		Version:       pkg.Version(),
+		Description:   pkg.Description(),
+		Repository:    repo,
+		Maintainer:    pkg.Packager(),
+		UpstreamURL:   pkg.URL(),
+		DependList:    convertDependList(pkg.Depends()),
+		LastUpdated:   pkg.BuildDate().UTC().Format("Jan. 2, 2006, 3 p.m. MST"),
+		InstallReason: reason,
+		InstallDate:   pkg.InstallDate().Unix(),
+		InstalledSize: pkg.ISize(),
+	}
+}
+
+// originRepo returns the first sync repository providing name, in
+// pacman.conf order, or "AUR" or "foreign" for packages not in any of them.
+// AUR packages are recognized from the package names of the search index.
+func originRepo(name string, syncDBs []alpm.IDB, aurNames map[string]bool) string {
+	for _, db := range syncDBs {
+		if db.Pkg(name) != nil {
+			return db.Name()
+		}
+	}
+	if aurNames[name] {
+		return repoAUR
+	}
+	return repoForeign
+}
+
+// indexAURNames returns the AUR package names of the search index, empty
+// when there is no index.
+func indexAURNames() map[string]bool {
+	aurNames := make(map[string]bool)
+	if idx := currentIndex(); idx != nil {
+		for _, name := range idx.AURNames {
+			aurNames[name] = true
+		}
+	}
+	return aurNames
+}
+
+// validate rejects filters that cannot match any package.
+func (f InstalledFilter) validate() error {
+	if f.Explicit && f.Deps {
+		return fmt.Errorf("explicit and deps filters are exclusive")
+	}
+	if f.Foreign && f.Native {
+		return fmt.Errorf("foreign and native filters are exclusive")
+	}
+	return nil
+}
+
+func (f InstalledFilter) matches(info PackageInfo) bool {
+	foreign := info.Repository == repoAUR || info.Repository == repoForeign
+	switch {
+	case f.Explicit && info.InstallReason != installReasonExplicit:
+		return false
+	case f.Deps && info.InstallReason != installReasonDependency:
+		return false
+	case f.Foreign && !foreign:
+		return false
+	case f.Native && foreign:
+		return false
+	case f.Repository != "" && info.Repository != f.Repository:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+func TestInstalledFilterMatches(t *testing.T) {
+	packages := []PackageInfo{
+		{Name: "linux", Repository: "core", InstallReason: installReasonExplicit},
+		{Name: "glibc", Repository: "core", InstallReason: installReasonDependency},
+		{Name: "yay", Repository: repoAUR, InstallReason: installReasonExplicit},
+		{Name: "libfoo", Repository: repoForeign, InstallReason: installReasonDependency},
+	}
+
+	tests := []struct {
+		name   string
+		filter InstalledFilter
+		want   string
+	}{
+		{"no filter", InstalledFilter{}, "linux glibc yay libfoo"},
+		{"explicit", InstalledFilter{Explicit: true}, "linux yay"},
+		{"deps", InstalledFilter{Deps: true}, "glibc libfoo"},
+		{"foreign", InstalledFilter{Foreign: true}, "yay libfoo"},
+		{"native", InstalledFilter{Native: true}, "linux glibc"},
+		{"repository", InstalledFilter{Repository: "core"}, "linux glibc"},
+		{"AUR", InstalledFilter{Repository: repoAUR}, "yay"},
+		{"combined", InstalledFilter{Native: true, Deps: true}, "glibc"},
+		{"unknown repository", InstalledFilter{Repository: "extra"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			for _, info := range packages {
+				if tt.filter.matches(info) {
+					if got != "" {
+						got += " "
+					}
+					got += info.Name
+				}
+			}
+			if got != tt.want {
+				t.Errorf("matched %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOriginRepo(t *testing.T) {
+	syncDBs := []alpm.IDB{
+		newFakeDB("core", &fakePkg{name: "linux"}),
+		newFakeDB("extra", &fakePkg{name: "linux"}, &fakePkg{name: "firefox"}),
+	}
+	aurNames := map[string]bool{"yay": true}
+
+	for name, want := range map[string]string{
+		"linux":   "core", // pacman.conf order decides
+		"firefox": "extra",
+		"yay":     repoAUR,
+		"libfoo":  repoForeign,
+	} {
+		if got := originRepo(name, syncDBs, aurNames); got != want {
+			t.Errorf("originRepo(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInstalledFilterValidate(t *testing.T) {
+	valid := []InstalledFilter{
+		{},
+		{Explicit: true, Native: true},
+		{Deps: true, Foreign: true, Repository: repoAUR},
+	}
+	for _, filter := range valid {
+		if err := filter.validate(); err != nil {
+			t.Errorf("validate(%+v) = %v", filter, err)
+		}
+	}
+	for _, filter := range []InstalledFilter{{Explicit: true, Deps: true}, {Foreign: true, Native: true}} {
+		if filter.validate() == nil {
+			t.Errorf("validate(%+v) accepted a filter that matches nothing", filter)
+		}
+	}
+}
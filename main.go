@@ -3,6 +3,7 @@ package main
 import (
 	"embed"
 	"log"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/logger"
@@ -20,6 +21,11 @@ var assets embed.FS
 var icon []byte
 
 func main() {
+	// Commands such as "apm search foo" run without the graphical interface
+	if isCLI(os.Args[1:]) {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -35,7 +41,7 @@ func main() {
 		DisableResize:     false,
 		Fullscreen:        true,
 		Frameless:         false,
-		StartHidden:       false,
+		StartHidden:       startedHidden(),
 		HideWindowOnClose: false,
 		BackgroundColour:  &options.RGBA{R: 255, G: 255, B: 255, A: 255},
 		CSSDragProperty:   "none",
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	newsFile      = "news.xml"
+	newsStateFile = "news.json"
+	newsTTL       = time.Hour
+	pacmanLog     = "/var/log/pacman.log"
+)
+
+// newsFeedURL is the Arch Linux news feed. It can be overridden with
+// APM_NEWS_URL, e.g. to point at a local copy.
+var newsFeedURL = envOr("APM_NEWS_URL", "https://archlinux.org/feeds/news/")
+
+var newsMu sync.Mutex
+
+// NewsItem is an entry of the Arch Linux news feed.
+type NewsItem struct {
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	Published int64  `json:"published"`
+	Summary   string `json:"summary"`
+	Read      bool   `json:"read"`
+}
+
+type rssFeed struct {
+	Items []struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		PubDate     string `xml:"pubDate"`
+		Description string `xml:"description"`
+	} `xml:"channel>item"`
+}
+
+// newsState records which news items the user acknowledged.
+type newsState struct {
+	Acknowledged []string `json:"acknowledged"`
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// configDir returns the per-user configuration directory of the application, creating it if needed.
+func configDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config dir: %w", err)
+	}
+	dir := filepath.Join(base, "apm")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create config dir: %w", err)
+	}
+	return dir, nil
+}
+
+// GetArchNews returns the Arch Linux news, newest first. The feed is cached
+// on disk and the cached copy is used when it cannot be fetched.
+func (a *App) GetArchNews() ([]NewsItem, error) {
+	newsMu.Lock()
+	defer newsMu.Unlock()
+
+	data, err := fetchNews()
+	if err != nil {
+		return nil, err
+	}
+
+	var feed rssFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse news feed: %w", err)
+	}
+
+	state := readNewsState()
+	acknowledged := make(map[string]bool, len(state.Acknowledged))
+	for _, link := range state.Acknowledged {
+		acknowledged[link] = true
+	}
+
+	items := make([]NewsItem, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		var published int64
+		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
+			published = t.Unix()
+		}
+		items = append(items, NewsItem{
+			Title:     strings.TrimSpace(item.Title),
+			Link:      strings.TrimSpace(item.Link),
+			Published: published,
+			Summary:   item.Description,
+			Read:      acknowledged[strings.TrimSpace(item.Link)],
+		})
+	}
+	return items, nil
+}
+
+// GetUnreadNews returns the news published since the last full system
+// upgrade that were not acknowledged yet. The UI should show these before
+// calling UpdateAllPkg.
+func (a *App) GetUnreadNews() ([]NewsItem, error) {
+	items, err := a.GetArchNews()
+	if err != nil {
+		return nil, err
+	}
+	since := lastUpgrade()
+
+	var unread []NewsItem
+	for _, item := range items {
+		if !item.Read && item.Published > since {
+			unread = append(unread, item)
+		}
+	}
+	return unread, nil
+}
+
+// AcknowledgeNews marks the news items with the given links as read.
+func (a *App) AcknowledgeNews(links []string) error {
+	newsMu.Lock()
+	defer newsMu.Unlock()
+
+	state := readNewsState()
+	for _, link := range links {
+		if !contains(state.Acknowledged, link) {
+			state.Acknowledged = append(state.Acknowledged, link)
+		}
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode news state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, newsStateFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to save news state: %w", err)
+	}
+	return nil
+}
+
+// fetchNews returns the raw news feed, downloading it when the cached copy
+// is older than newsTTL.
+func fetchNews() ([]byte, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, newsFile)
+
+	fi, statErr := os.Stat(path)
+	if statErr == nil && time.Since(fi.ModTime()) < newsTTL {
+		return os.ReadFile(path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	data, err := httpGet(ctx, newsFeedURL)
+	if err != nil {
+		if statErr == nil {
+			log.Printf("Error fetching news, using cached copy: %v", err)
+			return os.ReadFile(path)
+		}
+		return nil, fmt.Errorf("failed to fetch news: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Printf("Error caching news: %v", err)
+	}
+	return data, nil
+}
+
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func readNewsState() newsState {
+	var state newsState
+	dir, err := configDir()
+	if err != nil {
+		return state
+	}
+	data, err := os.ReadFile(filepath.Join(dir, newsStateFile))
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Error reading news state: %v", err)
+	}
+	return state
+}
+
+// lastUpgrade returns the Unix time of the last full system upgrade recorded
+// in pacman.log, or 0 if there is none.
+func lastUpgrade() int64 {
+	f, err := os.Open(pacmanLogPath())
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	return lastUpgradeFrom(f)
+}
+
+// lastUpgradeFrom returns the time of the last full system upgrade in a
+// pacman.log.
+func lastUpgradeFrom(r io.Reader) int64 {
+	var last int64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "starting full system upgrade") {
+			continue
+		}
+		// [2024-05-01T10:00:00+0200] [PACMAN] starting full system upgrade
+		end := strings.IndexByte(line, ']')
+		if !strings.HasPrefix(line, "[") || end < 0 {
+			continue
+		}
+		if t, err := time.Parse("2006-01-02T15:04:05-0700", line[1:end]); err == nil {
+			last = t.Unix()
+		}
+	}
+	return last
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFeed = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0">
+  <channel>
+    <title>Arch Linux: Recent news updates</title>
+    <item>
+      <title>  Manual intervention required  </title>
+      <link> https://archlinux.org/news/manual-intervention/ </link>
+      <description>&lt;p&gt;Run the command.&lt;/p&gt;</description>
+      <pubDate>Tue, 02 Jan 2024 10:00:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>Undated</title>
+      <link>https://archlinux.org/news/undated/</link>
+      <description></description>
+      <pubDate>yesterday</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+// withTestDirs points the cache and config directories at temporary ones
+// and the news feed at url.
+func withTestDirs(t *testing.T, url string) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	saved := newsFeedURL
+	newsFeedURL = url
+	t.Cleanup(func() { newsFeedURL = saved })
+}
+
+func TestGetArchNews(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		cached       string
+		acknowledged string
+		want         []NewsItem
+		wantErr      bool
+	}{
+		{
+			name:   "feed",
+			status: http.StatusOK,
+			body:   testFeed,
+			want: []NewsItem{
+				{
+					Title:     "Manual intervention required",
+					Link:      "https://archlinux.org/news/manual-intervention/",
+					Published: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).Unix(),
+					Summary:   "<p>Run the command.</p>",
+				},
+				{Title: "Undated", Link: "https://archlinux.org/news/undated/"},
+			},
+		},
+		{
+			name:         "acknowledged",
+			status:       http.StatusOK,
+			body:         testFeed,
+			acknowledged: `{"acknowledged": ["https://archlinux.org/news/undated/"]}`,
+			want: []NewsItem{
+				{
+					Title:     "Manual intervention required",
+					Link:      "https://archlinux.org/news/manual-intervention/",
+					Published: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).Unix(),
+					Summary:   "<p>Run the command.</p>",
+				},
+				{Title: "Undated", Link: "https://archlinux.org/news/undated/", Read: true},
+			},
+		},
+		{
+			name:   "empty feed",
+			status: http.StatusOK,
+			body:   `<rss version="2.0"><channel></channel></rss>`,
+			want:   []NewsItem{},
+		},
+		{
+			name:    "invalid feed",
+			status:  http.StatusOK,
+			body:    `<rss><channel><item>`,
+			wantErr: true,
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+		{
+			name:   "server error with stale cache",
+			status: http.StatusInternalServerError,
+			cached: `<rss version="2.0"><channel><item><title>Cached</title><link>https://archlinux.org/news/cached/</link></item></channel></rss>`,
+			want:   []NewsItem{{Title: "Cached", Link: "https://archlinux.org/news/cached/"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			withTestDirs(t, srv.URL)
+
+			if tt.cached != "" {
+				dir, err := cacheDir()
+				if err != nil {
+					t.Fatal(err)
+				}
+				path := filepath.Join(dir, newsFile)
+				if err := os.WriteFile(path, []byte(tt.cached), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				old := time.Now().Add(-2 * newsTTL)
+				if err := os.Chtimes(path, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.acknowledged != "" {
+				dir, err := configDir()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, newsStateFile), []byte(tt.acknowledged), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := (&App{}).GetArchNews()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetArchNews() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetArchNews() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetArchNewsUsesFreshCache(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(testFeed))
+	}))
+	defer srv.Close()
+	withTestDirs(t, srv.URL)
+
+	a := &App{}
+	for i := 0; i < 2; i++ {
+		if _, err := a.GetArchNews(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("feed fetched %d times, want 1", requests)
+	}
+}
+
+func TestLastUpgradeFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want int64
+	}{
+		{
+			name: "empty",
+			want: 0,
+		},
+		{
+			name: "no full upgrade",
+			log: `[2024-05-01T10:00:00+0200] [PACMAN] Running 'pacman -S vim'
+[2024-05-01T10:00:01+0200] [ALPM] transaction started
+[2024-05-01T10:00:02+0200] [ALPM] installed vim (9.1.0-1)`,
+			want: 0,
+		},
+		{
+			name: "last of several",
+			log: `[2024-05-01T10:00:00+0200] [PACMAN] starting full system upgrade
+[2024-05-01T10:00:02+0200] [ALPM] upgraded vim (9.0.0-1 -> 9.1.0-1)
+[2024-05-03T08:30:00+0000] [PACMAN] starting full system upgrade
+[2024-05-04T09:00:00+0000] [PACMAN] Running 'pacman -S git'`,
+			want: time.Date(2024, 5, 3, 8, 30, 0, 0, time.UTC).Unix(),
+		},
+		{
+			name: "old log format ignored",
+			log: `[2024-05-01T10:00:00+0200] [PACMAN] starting full system upgrade
+[2018-01-01 10:00] starting full system upgrade`,
+			want: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC).Unix(),
+		},
+		{
+			name: "malformed line",
+			log:  `starting full system upgrade]`,
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastUpgradeFrom(strings.NewReader(tt.log)); got != tt.want {
+				t.Errorf("lastUpgradeFrom() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// RemovalPlan is the set of packages an orphan cleanup would remove. It is
+// shown to the user before RemoveOrphans is called with the same packages.
+type RemovalPlan struct {
+	Packages  []PackageInfo `json:"packages"`
+	TotalSize int64         `json:"totalSize"`
+}
+
+// GetOrphans returns the packages installed as dependencies that no installed
+// package requires anymore, like pacman -Qdtt. With keepOptional set packages
+// that are an optional dependency of an installed package are kept, like
+// pacman -Qdt.
+func (a *App) GetOrphans(keepOptional bool) ([]PackageInfo, error) {
+	return localOrphans(keepOptional, false)
+}
+
+// PlanOrphanRemoval returns everything RemoveOrphans would remove: the
+// orphans and the dependencies that only they require, recursively.
+func (a *App) PlanOrphanRemoval(keepOptional bool) (*RemovalPlan, error) {
+	packages, err := localOrphans(keepOptional, true)
+	if err != nil {
+		return nil, err
+	}
+	plan := &RemovalPlan{Packages: packages}
+	for _, pkg := range packages {
+		plan.TotalSize += pkg.InstalledSize
+	}
+	return plan, nil
+}
+
+// RemoveOrphans removes the packages of a plan returned by PlanOrphanRemoval.
+// It fails without removing anything when the removal set changed since, so
+// nothing is removed that the user did not see.
+func (a *App) RemoveOrphans(names []string, keepOptional bool) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no packages selected")
+	}
+	plan, err := a.PlanOrphanRemoval(keepOptional)
+	if err != nil {
+		return err
+	}
+
+	var current []string
+	for _, pkg := range plan.Packages {
+		current = append(current, pkg.Name)
+	}
+	confirmed := append([]string(nil), names...)
+	sort.Strings(confirmed)
+	sort.Strings(current)
+	if strings.Join(confirmed, " ") != strings.Join(current, " ") {
+		return fmt.Errorf("the set of orphans changed, review it again")
+	}
+
+	// The set already contains the dependencies, so -s is not needed and
+	// pacman removes exactly what was shown.
+	args := append([]string{"pacman", "-Rn", "--noconfirm"}, confirmed...)
+	if err := runPrivileged(args...); err != nil {
+		return fmt.Errorf("failed to remove orphans: %w", err)
+	}
+	return nil
+}
+
+// localOrphans finds the orphans in the local database. With recursive set
+// it keeps going with the packages only required by orphans.
+func localOrphans(keepOptional, recursive bool) ([]PackageInfo, error) {
+	h, syncDBs, err := openSystemDB()
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+
+	// Candidates are the dependencies, with the packages they are needed by
+	candidates := make(map[string]alpm.IPackage)
+	neededBy := make(map[string][]string)
+	for _, pkg := range localDB.PkgCache().Slice() {
+		if pkg.Reason() != alpm.PkgReasonDepend {
+			continue
+		}
+		candidates[pkg.Name()] = pkg
+		neededBy[pkg.Name()] = pkg.ComputeRequiredBy()
+		if keepOptional {
+			neededBy[pkg.Name()] = append(neededBy[pkg.Name()], pkg.ComputeOptionalFor()...)
+		}
+	}
+
+	orphans := findOrphans(neededBy, recursive)
+
+	aurNames := indexAURNames()
+	var packages []PackageInfo
+	for name := range orphans {
+		packages = append(packages, installedPackageInfo(candidates[name], originRepo(name, syncDBs, aurNames)))
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages, nil
+}
+
+// findOrphans returns the candidates, the keys of neededBy, that no package
+// outside the orphans needs. With recursive set, packages only needed by
+// orphans are orphans as well.
+func findOrphans(neededBy map[string][]string, recursive bool) map[string]bool {
+	orphans := make(map[string]bool)
+	for {
+		var found []string
+		for name := range neededBy {
+			if orphans[name] {
+				continue
+			}
+			needed := false
+			for _, by := range neededBy[name] {
+				if !orphans[by] {
+					needed = true
+					break
+				}
+			}
+			if !needed {
+				found = append(found, name)
+			}
+		}
+		for _, name := range found {
+			orphans[name] = true
+		}
+		if !recursive || len(found) == 0 {
+			break
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindOrphans(t *testing.T) {
+	// Dependencies installed as such, with the packages that need them
+	neededBy := map[string][]string{
+		"libold":    {},
+		"libdep":    {"libold"},
+		"libdeeper": {"libdep"},
+		"libused":   {"firefox"},
+		"libshared": {"libold", "firefox"},
+		"cycle-a":   {"cycle-b"},
+		"cycle-b":   {"cycle-a"},
+	}
+
+	got := findOrphans(neededBy, false)
+	if want := map[string]bool{"libold": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans(recursive=false) = %v, want %v", got, want)
+	}
+
+	got = findOrphans(neededBy, true)
+	want := map[string]bool{"libold": true, "libdep": true, "libdeeper": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans(recursive=true) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+// Exit codes of undoScript telling which step failed.
+const (
+	undoRemoveFailed  = 3
+	undoInstallFailed = 4
+	undoReasonFailed  = 5
+)
+
+// undoScript checks the package files in the arguments after $2, removes the
+// packages listed in $1 without dependency checks, installs the package files
+// with dependency checks and marks the packages listed in $2 as dependencies.
+// Lists are separated by spaces; names are validated by the caller, and
+// globbing is disabled for the unquoted lists.
+const undoScript = `set -ef
+remove=$1
+asdeps=$2
+shift 2
+if [ $# -gt 0 ]; then
+	pacman -Qip "$@" >/dev/null || exit 3
+fi
+if [ -n "$remove" ]; then
+	pacman -Rndd --noconfirm $remove || exit 3
+fi
+if [ $# -gt 0 ]; then
+	pacman -U --noconfirm "$@" || exit 4
+fi
+if [ -n "$asdeps" ]; then
+	pacman -D --asdeps $asdeps || exit 5
+fi`
+
+// UndoPlan is the inverse of a transaction, shown to the user before
+// UndoLastTransaction is called.
+type UndoPlan struct {
+	Transaction Transaction `json:"transaction"`
+	// Remove are the packages the transaction installed.
+	Remove []string `json:"remove"`
+	// Install are the previous versions of upgraded, downgraded and removed
+	// packages, found in the package cache.
+	Install []UndoPackage `json:"install"`
+	// Missing are previous versions that are not in the package cache. They
+	// can be downloaded with Downgrade if the archive has them.
+	Missing []UndoPackage `json:"missing"`
+	// Changed are packages that changed again after the transaction, so
+	// undoing it would also revert the later change.
+	Changed []string `json:"changed"`
+}
+
+// UndoPackage is a package version to reinstall.
+type UndoPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	File    string `json:"file,omitempty"`
+	// Dependency is set for removed packages that were installed as
+	// dependencies, so they get that install reason back. Upgraded and
+	// downgraded packages keep their install reason anyway.
+	Dependency bool `json:"dependency,omitempty"`
+}
+
+// PlanUndoLastTransaction computes how to revert the last completed
+// transaction of pacman.log: packages it installed are removed and previous
+// versions of the packages it upgraded, downgraded or removed are installed
+// from the package cache.
+func (a *App) PlanUndoLastTransaction() (*UndoPlan, error) {
+	transactions, err := a.GetTransactionHistory(HistoryFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var last *Transaction
+	for i := range transactions {
+		if transactions[i].Status == "completed" && len(transactions[i].Actions) > 0 {
+			last = &transactions[i]
+			break
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("no transaction to undo")
+	}
+
+	conf, _, err := paconf.ParseFile(pacmanConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pacman config: %v", err)
+	}
+	h, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	defer h.Release()
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+
+	plan := &UndoPlan{Transaction: *last}
+	for _, action := range last.Actions {
+		// The package must still be as the transaction left it
+		current := ""
+		if pkg := localDB.Pkg(action.Name); pkg != nil {
+			current = pkg.Version()
+		}
+		if current != action.NewVersion {
+			plan.Changed = append(plan.Changed, action.Name)
+		}
+
+		switch action.Action {
+		case "installed":
+			if current != "" {
+				plan.Remove = append(plan.Remove, action.Name)
+			}
+		case "upgraded", "downgraded", "removed":
+			previous := UndoPackage{Name: action.Name, Version: action.OldVersion}
+			if action.Action == "removed" {
+				previous.Dependency = installedAsDependency(transactions, action.Name)
+			}
+			if file, ok := cachedPackages(conf, action.Name)[action.OldVersion]; ok {
+				previous.File = file
+				plan.Install = append(plan.Install, previous)
+			} else {
+				plan.Missing = append(plan.Missing, previous)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// installedAsDependency guesses from the history, newest first, whether
+// name was installed as a dependency: the last transaction that installed it
+// must have a logged command line that does not name it. Without such a
+// transaction the package is taken as explicitly installed.
+func installedAsDependency(transactions []Transaction, name string) bool {
+	for _, transaction := range transactions {
+		for _, action := range transaction.Actions {
+			if action.Name != name || action.Action != "installed" {
+				continue
+			}
+			if transaction.Command == "" {
+				return false
+			}
+			for _, arg := range strings.Fields(transaction.Command) {
+				if arg == name || strings.HasSuffix(arg, "/"+name) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// UndoLastTransaction carries out a plan returned by PlanUndoLastTransaction.
+// started is the start of the planned transaction; the call fails without
+// changing anything when another transaction happened since, or when
+// previous versions are missing from the cache.
+func (a *App) UndoLastTransaction(started int64) error {
+	plan, err := a.PlanUndoLastTransaction()
+	if err != nil {
+		return err
+	}
+	if plan.Transaction.Started != started {
+		return fmt.Errorf("another transaction happened since, review the undo again")
+	}
+	if len(plan.Missing) > 0 {
+		var missing []string
+		for _, pkg := range plan.Missing {
+			missing = append(missing, pkg.Name+"-"+pkg.Version)
+		}
+		return fmt.Errorf("previous versions missing from the package cache: %s", strings.Join(missing, ", "))
+	}
+
+	// A single privileged script removes the installed packages first, so
+	// that previous versions they replaced or conflict with can go back in
+	// without conflict prompts, then reinstalls the previous versions. Only
+	// the removal skips dependency checks, as the previous versions installed
+	// right after take the place of the removed packages. The package files
+	// are checked before anything is removed.
+	var remove, asdeps []string
+	for _, name := range plan.Remove {
+		if !pkgNameRe.MatchString(name) {
+			return fmt.Errorf("invalid package name %q", name)
+		}
+		remove = append(remove, name)
+	}
+	var files []string
+	for _, pkg := range plan.Install {
+		if !pkgNameRe.MatchString(pkg.Name) {
+			return fmt.Errorf("invalid package name %q", pkg.Name)
+		}
+		if pkg.Dependency {
+			asdeps = append(asdeps, pkg.Name)
+		}
+		files = append(files, pkg.File)
+	}
+	args := append([]string{"sh", "-c", undoScript, "sh", strings.Join(remove, " "), strings.Join(asdeps, " ")}, files...)
+
+	err = runPrivileged(args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case undoRemoveFailed:
+			return fmt.Errorf("failed to remove installed packages, nothing was changed: %w", err)
+		case undoInstallFailed:
+			if len(plan.Remove) == 0 {
+				return fmt.Errorf("failed to reinstall previous versions, nothing was changed: %w", err)
+			}
+			return fmt.Errorf("removed %s but failed to reinstall previous versions; install them with %q to finish the undo: %w",
+				strings.Join(plan.Remove, ", "), "pacman -U "+strings.Join(files, " "), err)
+		case undoReasonFailed:
+			return fmt.Errorf("undid the transaction but failed to mark %s as dependencies: %w", strings.Join(asdeps, ", "), err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to undo the transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestInstalledAsDependency(t *testing.T) {
+	installed := func(command string, names ...string) Transaction {
+		transaction := Transaction{Command: command, Status: "completed"}
+		for _, name := range names {
+			transaction.Actions = append(transaction.Actions, PackageAction{Action: "installed", Name: name})
+		}
+		return transaction
+	}
+	// Newest first, as returned by GetTransactionHistory
+	history := []Transaction{
+		{Actions: []PackageAction{{Action: "removed", Name: "gimp"}, {Action: "removed", Name: "babl"}}},
+		installed("pacman -S --config /etc/pacman.conf -- extra/gimp", "gimp", "babl", "gegl"),
+		installed("pacman -Syu", "libfoo"),
+		installed("", "unlogged"),
+		installed("pacman -S gegl", "gegl"),
+	}
+
+	for name, want := range map[string]bool{
+		"gimp":     false,
+		"babl":     true,
+		"gegl":     true, // the newest installation decides
+		"libfoo":   true,
+		"unlogged": false,
+		"unknown":  false,
+	} {
+		if got := installedAsDependency(history, name); got != want {
+			t.Errorf("installedAsDependency(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
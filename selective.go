@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+// CheckPartialUpgrade returns the pending official repository updates that
+// would be left behind when only the given packages are upgraded. Upgrading
+// some official packages while leaving others at older versions is a partial
+// upgrade, which Arch does not support. The check uses the system databases,
+// like UpdatePackages, so they should be refreshed first.
+func (a *App) CheckPartialUpgrade(names []string) ([]string, error) {
+	_, pending, _, err := a.partialUpgrade(names)
+	return pending, err
+}
+
+// partialUpgrade runs findPartialUpgrade against the system databases.
+func (a *App) partialUpgrade(names []string) (official bool, pending, current []string, err error) {
+	h, _, err := openSystemDB()
+	if err != nil {
+		return false, nil, nil, err
+	}
+	defer h.Release()
+
+	conf, _, err := paconf.ParseFile(pacmanConf)
+	if err != nil {
+		return false, nil, nil, fmt.Errorf("failed to parse pacman config: %v", err)
+	}
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return false, nil, nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+	syncDBs, err := h.SyncDBs()
+	if err != nil {
+		return false, nil, nil, fmt.Errorf("failed to get sync DBs: %v", err)
+	}
+
+	official, pending, current = findPartialUpgrade(localDB, syncDBs, a.newUpdateFilter(conf), names)
+	return official, pending, current, nil
+}
+
+// findPartialUpgrade reports whether names contain official packages with
+// pending updates and which other official updates would then be left
+// behind. current lists the selected official packages that have no update.
+// Replacements count as official updates of the packages they replace, and
+// updates excluded by IgnorePkg, IgnoreGroup or the session ignore list are
+// never pending, as a full upgrade would not apply them either.
+func findPartialUpgrade(localDB alpm.IDB, syncDBs alpm.IDBList, filter updateFilter, names []string) (official bool, pending, current []string) {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	updated := make(map[string]bool)
+	add := func(update UpdateInfo) {
+		switch {
+		case selected[update.Name]:
+			official = true
+			updated[update.Name] = true
+		case !update.Ignored:
+			pending = append(pending, update.Name)
+		}
+	}
+
+	localDB.PkgCache().ForEach(func(pkg alpm.IPackage) error {
+		newPkg := pkg.SyncNewVersion(syncDBs)
+		if newPkg == nil {
+			return nil
+		}
+		update := UpdateInfo{Name: pkg.Name()}
+		filter.apply(&update, newPkg.Groups().Slice())
+		add(update)
+		return nil
+	})
+	for _, replacement := range findReplacements(localDB, syncDBs, filter, nil) {
+		add(replacement)
+	}
+
+	for _, name := range names {
+		if !updated[name] && localDB.Pkg(name) != nil && inSyncDBs(syncDBs, name) {
+			current = append(current, name)
+		}
+	}
+
+	// Only upgrading official packages can leave the system partially upgraded
+	if !official {
+		return false, nil, current
+	}
+	sort.Strings(pending)
+	return true, pending, current
+}
+
+// inSyncDBs reports whether one of the sync databases has a package called name.
+func inSyncDBs(syncDBs alpm.IDBList, name string) bool {
+	found := false
+	syncDBs.ForEach(func(db alpm.IDB) error {
+		if db.Pkg(name) != nil {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// UpdatePackages upgrades the given packages in a single transaction. Official
+// packages are upgraded from the system databases without synchronizing them,
+// so the call fails when they need a refresh first or when other official
+// updates would be left behind, as that would be a partial upgrade.
+func (a *App) UpdatePackages(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no packages selected")
+	}
+	for _, name := range names {
+		if !pkgNameRe.MatchString(name) {
+			return fmt.Errorf("invalid package name %q", name)
+		}
+	}
+
+	_, pending, current, err := a.partialUpgrade(names)
+	if err != nil {
+		return err
+	}
+	if len(current) > 0 {
+		return fmt.Errorf("no update available for %s, refresh the databases first", strings.Join(current, ", "))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("partial upgrade: %d other official updates would be left behind: %s", len(pending), strings.Join(pending, ", "))
+	}
+
+	args := append([]string{"yay", "-S", "--needed", "--noconfirm"}, names...)
+	if err := runPrivileged(args...); err != nil {
+		return fmt.Errorf("failed to update packages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+func TestFindPartialUpgrade(t *testing.T) {
+	local := newFakeDB("local",
+		&fakePkg{name: "linux", version: "6.1-1"},
+		&fakePkg{name: "glibc", version: "2.38-1"},
+		&fakePkg{name: "bash", version: "5.2-1"},
+		&fakePkg{name: "yay", version: "12.0-1"},
+		&fakePkg{name: "oldname", version: "1.0-1"},
+	)
+	syncDBs := fakeDBList{
+		newFakeDB("core",
+			&fakePkg{name: "linux", version: "6.2-1"},
+			&fakePkg{name: "glibc", version: "2.39-1"},
+			&fakePkg{name: "bash", version: "5.2-1"},
+		),
+		newFakeDB("extra",
+			&fakePkg{name: "newname", version: "2.0-1", replaces: []alpm.Depend{{Name: "oldname"}}},
+		),
+	}
+
+	tests := []struct {
+		name     string
+		names    []string
+		filter   updateFilter
+		official bool
+		pending  []string
+		current  []string
+	}{
+		{
+			name:     "subset leaves updates behind",
+			names:    []string{"linux"},
+			official: true,
+			pending:  []string{"glibc", "newname"},
+		},
+		{
+			name:     "all official updates",
+			names:    []string{"linux", "glibc", "newname"},
+			official: true,
+		},
+		{
+			name:     "ignored updates are not pending",
+			names:    []string{"linux", "newname"},
+			filter:   updateFilter{ignorePkg: []string{"glib*"}},
+			official: true,
+		},
+		{
+			name:  "foreign packages only",
+			names: []string{"yay"},
+		},
+		{
+			name:     "package without update",
+			names:    []string{"bash", "linux", "glibc", "newname"},
+			official: true,
+			current:  []string{"bash"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			official, pending, current := findPartialUpgrade(local, syncDBs, tt.filter, tt.names)
+			if official != tt.official {
+				t.Errorf("official = %v, want %v", official, tt.official)
+			}
+			if !reflect.DeepEqual(pending, tt.pending) {
+				t.Errorf("pending = %v, want %v", pending, tt.pending)
+			}
+			if !reflect.DeepEqual(current, tt.current) {
+				t.Errorf("current = %v, want %v", current, tt.current)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const settingsFile = "settings.json"
+
+// Settings are the user preferences of the application.
+type Settings struct {
+	// CheckInterval is the background update check interval in minutes; 0
+	// disables background checks.
+	CheckInterval int `json:"checkInterval"`
+	// CheckOnBattery and CheckOnMetered allow background checks while
+	// running on battery or on a metered connection.
+	CheckOnBattery bool `json:"checkOnBattery"`
+	CheckOnMetered bool `json:"checkOnMetered"`
+	// Notifications enables desktop notifications about available updates.
+	Notifications bool `json:"notifications"`
+	// ResidentMode keeps the application running in the tray when the
+	// window is closed.
+	ResidentMode bool `json:"residentMode"`
+}
+
+var defaultSettings = Settings{
+	CheckInterval: 180,
+	Notifications: true,
+}
+
+var settingsMu sync.Mutex
+
+// GetSettings returns the saved settings, or the defaults if none were saved.
+func (a *App) GetSettings() Settings {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	return loadSettings()
+}
+
+// SaveSettings stores the settings.
+func (a *App) SaveSettings(settings Settings) error {
+	if settings.CheckInterval < 0 {
+		return fmt.Errorf("invalid check interval %d", settings.CheckInterval)
+	}
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, settingsFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	a.applyTraySetting(settings)
+	return nil
+}
+
+func loadSettings() Settings {
+	settings := defaultSettings
+	dir, err := configDir()
+	if err != nil {
+		return settings
+	}
+	data, err := os.ReadFile(filepath.Join(dir, settingsFile))
+	if err != nil {
+		return settings
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		log.Printf("Error reading settings: %v", err)
+		return defaultSettings
+	}
+	return settings
+}
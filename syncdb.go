@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+	paconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+const (
+	pacmanConf   = "/etc/pacman.conf"
+	pacmanDBPath = "/var/lib/pacman"
+
+	// Stamp files in the cache directory recording when the application last
+	// refreshed the databases. The database mtimes cannot be used, as pacman
+	// sets them to the Last-Modified time of the mirror.
+	refreshStamp      = "last-refresh"
+	filesRefreshStamp = "last-files-refresh"
+)
+
+// RefreshProgress is emitted as a "refresh:progress" event while databases are synchronized.
+type RefreshProgress struct {
+	Repository string `json:"repository"`
+	Status     string `json:"status"` // downloading, up to date, done or failed
+	Downloaded int64  `json:"downloaded"`
+	Total      int64  `json:"total"`
+}
+
+// RefreshDatabases synchronizes the sync databases, like pacman -Sy. When
+// checkOnly is set nothing is changed on the system: the databases are
+// downloaded as the current user into a temporary copy of the DBPath, as
+// checkupdates does, so the real databases never get ahead of the installed
+// packages, and the pending updates are checked again.
+func (a *App) RefreshDatabases(checkOnly bool) error {
+	if checkOnly {
+		if _, err := a.checkUpdates(true); err != nil {
+			return err
+		}
+		a.emit("refresh:progress", RefreshProgress{Status: "done"})
+		return nil
+	}
+
+	if err := a.pacmanSync("-Sy"); err != nil {
+		return fmt.Errorf("failed to refresh databases: %w", err)
+	}
+	recordRefresh(refreshStamp)
+
+	reloadSyncDBs()
+	a.emit("refresh:progress", RefreshProgress{Status: "done"})
+	return nil
+}
+
+// pacmanSync runs a privileged pacman database sync, -Sy or -Fy, and emits
+// its per-repository progress as "refresh:progress" events.
+func (a *App) pacmanSync(op string) error {
+	cmd := exec.CommandContext(a.ctx, "pkexec", "pacman", op)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var errBuffer strings.Builder
+	cmd.Stderr = &errBuffer
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Without a terminal pacman prints one line per repository, either
+	// " core downloading..." or " core is up to date".
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(line, "::") {
+			continue
+		}
+		progress := RefreshProgress{Repository: fields[0]}
+		switch {
+		case strings.HasSuffix(line, "is up to date"):
+			progress.Status = "up to date"
+		case strings.Contains(line, "downloading"):
+			progress.Status = "downloading"
+		case strings.Contains(line, "failed"):
+			progress.Status = "failed"
+		default:
+			continue
+		}
+		a.emit("refresh:progress", progress)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(errBuffer.String()))
+	}
+	return nil
+}
+
+// GetLastRefresh returns when the application last refreshed the system sync
+// databases as a Unix timestamp, or 0 if it never did.
+func (a *App) GetLastRefresh() int64 {
+	return lastRefresh(refreshStamp)
+}
+
+// recordRefresh updates a refresh stamp file to the current time.
+func recordRefresh(stamp string) {
+	dir, err := cacheDir()
+	if err != nil {
+		log.Printf("Error recording refresh: %v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, stamp), nil, 0o644); err != nil {
+		log.Printf("Error recording refresh: %v", err)
+		return
+	}
+	now := time.Now()
+	os.Chtimes(filepath.Join(dir, stamp), now, now)
+}
+
+// lastRefresh returns the time recorded in a refresh stamp file, or 0.
+func lastRefresh(stamp string) int64 {
+	dir, err := cacheDir()
+	if err != nil {
+		return 0
+	}
+	fi, err := os.Stat(filepath.Join(dir, stamp))
+	if err != nil {
+		return 0
+	}
+	return fi.ModTime().Unix()
+}
+
+// reloadSyncDBs replaces the global handle with a new one so that it picks
+// up freshly downloaded databases. The databases of the old handle are never
+// unregistered in place: readers hold handleMu while they use them, and the
+// old handle is only released once the new one is swapped in.
+func reloadSyncDBs() {
+	names := syncDBNames()
+	handle, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		log.Printf("Error reloading sync dbs: failed to initialize alpm: %v", err)
+		return
+	}
+
+	var registered []alpm.IDB
+	for _, name := range names {
+		db, err := handle.RegisterSyncDB(name, 0)
+		if err != nil {
+			log.Printf("Error getting sync db for %s: %v", name, err)
+			continue
+		}
+		registered = append(registered, db)
+	}
+
+	handleMu.Lock()
+	old := h
+	h, dbs = handle, registered
+	handleMu.Unlock()
+
+	if old != nil {
+		old.Release()
+	}
+
+	// Have the next search look for changed databases
+	lastStaleCheck.Store(0)
+}
+
+// syncDBNames returns the names of the repositories registered on the global
+// handle.
+func syncDBNames() []string {
+	handleMu.RLock()
+	defer handleMu.RUnlock()
+	names := make([]string, 0, len(dbs))
+	for _, db := range dbs {
+		names = append(names, db.Name())
+	}
+	return names
+}
+
+// syncCheckDB downloads the sync databases configured in pacman.conf into
+// dbPath, which gets a symlink to the real local database so that package
+// versions can be compared without touching the system databases.
+func (a *App) syncCheckDB(dbPath string) error {
+	conf, _, err := paconf.ParseFile(pacmanConf)
+	if err != nil {
+		return fmt.Errorf("failed to parse pacman config: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dbPath, "sync"), 0o755); err != nil {
+		return fmt.Errorf("failed to create database dir: %w", err)
+	}
+	local := filepath.Join(dbPath, "local")
+	if _, err := os.Lstat(local); os.IsNotExist(err) {
+		if err := os.Symlink(filepath.Join(conf.DBPath, "local"), local); err != nil {
+			return fmt.Errorf("failed to link local database: %w", err)
+		}
+	}
+
+	// A repository that cannot be reached, e.g. offline or on a mirror
+	// failure, keeps the copy seeded from the system databases so that the
+	// other repositories and the AUR can still be checked.
+	for _, repo := range conf.Repos {
+		path := filepath.Join(dbPath, "sync", repo.Name+".db")
+		if err := a.downloadSyncDB(repo, path); err != nil {
+			log.Printf("Error synchronizing %s, using the system database: %v", repo.Name, err)
+			a.emit("refresh:progress", RefreshProgress{Repository: repo.Name, Status: "failed"})
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				if err := copyFile(filepath.Join(conf.DBPath, "sync", repo.Name+".db"), path); err != nil {
+					log.Printf("Error copying system database %s: %v", repo.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// openCheckDB synchronizes a private copy of the databases as the current
+// user, like checkupdates, and returns a handle on it with the repositories of
+// pacman.conf registered. This gives fresh data without touching the system
+// databases. release closes the handle and removes the copy.
+func (a *App) openCheckDB() (handle *alpm.Handle, conf *paconf.Config, release func(), err error) {
+	dbPath, err := os.MkdirTemp("", "apm-checkup-db-")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create temporary database dir: %v", err)
+	}
+
+	seedCheckDB(dbPath)
+	if err := a.syncCheckDB(dbPath); err != nil {
+		os.RemoveAll(dbPath)
+		return nil, nil, nil, err
+	}
+
+	conf, _, err = paconf.ParseFile(pacmanConf)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		return nil, nil, nil, fmt.Errorf("failed to parse pacman config: %v", err)
+	}
+
+	handle, err = alpm.Initialize("/", dbPath)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		return nil, nil, nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	release = func() {
+		handle.Release()
+		os.RemoveAll(dbPath)
+	}
+
+	for _, repo := range conf.Repos {
+		db, err := handle.RegisterSyncDB(repo.Name, 0)
+		if err != nil {
+			release()
+			return nil, nil, nil, fmt.Errorf("failed to register sync db %s: %v", repo.Name, err)
+		}
+		db.SetServers(repo.Servers)
+	}
+	return handle, conf, release, nil
+}
+
+// openSystemDB returns a new handle on the system databases with the
+// repositories of pacman.conf registered, in pacman.conf order. Unlike the
+// global handle it can be used from any goroutine that owns it.
+func openSystemDB() (*alpm.Handle, []alpm.IDB, error) {
+	handle, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	syncDBs, err := registerConfRepos(handle)
+	if err != nil {
+		handle.Release()
+		return nil, nil, err
+	}
+	return handle, syncDBs, nil
+}
+
+// registerConfRepos registers the repositories of pacman.conf on handle.
+func registerConfRepos(handle *alpm.Handle) ([]alpm.IDB, error) {
+	conf, _, err := paconf.ParseFile(pacmanConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pacman config: %v", err)
+	}
+	var syncDBs []alpm.IDB
+	for _, repo := range conf.Repos {
+		db, err := handle.RegisterSyncDB(repo.Name, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register sync db %s: %v", repo.Name, err)
+		}
+		syncDBs = append(syncDBs, db)
+	}
+	return syncDBs, nil
+}
+
+// seedCheckDB copies the system sync databases into dbPath, keeping their
+// modification times, so that only repositories that changed since the last
+// pacman -Sy have to be downloaded again.
+func seedCheckDB(dbPath string) {
+	if err := os.MkdirAll(filepath.Join(dbPath, "sync"), 0o755); err != nil {
+		return
+	}
+	matches, _ := filepath.Glob(filepath.Join(pacmanDBPath, "sync", "*.db"))
+	for _, src := range matches {
+		if err := copyFile(src, filepath.Join(dbPath, "sync", filepath.Base(src))); err != nil {
+			log.Printf("Error copying %s: %v", src, err)
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+// downloadSyncDB fetches the database of repo from the first server that
+// answers. The file is only transferred when the server copy is newer.
+func (a *App) downloadSyncDB(repo paconf.Repository, path string) error {
+	if len(repo.Servers) == 0 {
+		return fmt.Errorf("no servers configured")
+	}
+
+	var lastErr error
+	for _, server := range repo.Servers {
+		err := a.downloadFile(repo.Name, strings.TrimSuffix(server, "/")+"/"+repo.Name+".db", path)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		log.Printf("Error downloading %s from %s: %v", repo.Name, server, err)
+	}
+	return lastErr
+}
+
+func (a *App) downloadFile(repoName, url, path string) error {
+	// Local repositories, Server = file:///path
+	if src, ok := strings.CutPrefix(url, "file://"); ok {
+		return a.copyNewer(repoName, src, path)
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, 10*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		req.Header.Set("If-Modified-Since", fi.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		a.emit("refresh:progress", RefreshProgress{Repository: repoName, Status: "up to date"})
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	progress := RefreshProgress{Repository: repoName, Status: "downloading", Total: resp.ContentLength}
+	buf := make([]byte, 64*1024)
+	lastEmit := time.Time{}
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := tmp.Write(buf[:n]); err != nil {
+				tmp.Close()
+				return err
+			}
+			progress.Downloaded += int64(n)
+			if time.Since(lastEmit) > 100*time.Millisecond {
+				a.emit("refresh:progress", progress)
+				lastEmit = time.Now()
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			tmp.Close()
+			return readErr
+		}
+	}
+	a.emit("refresh:progress", progress)
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(path, modified, modified)
+	}
+	return nil
+}
+
+// copyNewer copies a database from a local repository when it is newer than
+// the copy at path.
+func (a *App) copyNewer(repoName, src, path string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil && !srcInfo.ModTime().After(fi.ModTime()) {
+		a.emit("refresh:progress", RefreshProgress{Repository: repoName, Status: "up to date"})
+		return nil
+	}
+	if err := copyFile(src, path); err != nil {
+		return err
+	}
+	a.emit("refresh:progress", RefreshProgress{Repository: repoName, Status: "downloading", Downloaded: srcInfo.Size(), Total: srcInfo.Size()})
+	return nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Wails has no tray support on Linux, so the tray icon is a
+// StatusNotifierItem with a DBusMenu, both exported over the session bus.
+const (
+	sniInterface  = "org.kde.StatusNotifierItem"
+	sniPath       = dbus.ObjectPath("/StatusNotifierItem")
+	menuInterface = "com.canonical.dbusmenu"
+	menuPath      = dbus.ObjectPath("/MenuBar")
+
+	menuItemOpen = 1
+	menuItemQuit = 2
+
+	autostartFile = "apm.desktop"
+)
+
+// trayIcon is a running status notifier item.
+type trayIcon struct {
+	app   *App
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// trayItem implements the org.kde.StatusNotifierItem methods.
+type trayItem struct{ tray *trayIcon }
+
+// trayMenu implements the com.canonical.dbusmenu methods.
+type trayMenu struct{ tray *trayIcon }
+
+// menuLayout is a DBusMenu layout node, (ia{sv}av) on the wire.
+type menuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+// sniToolTip is the StatusNotifierItem tool tip, (sa(iiay)ss) on the wire.
+type sniToolTip struct {
+	IconName string
+	Icon     []sniPixmap
+	Title    string
+	Text     string
+}
+
+type sniPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+var trayMu sync.Mutex
+
+// startTray shows the tray icon if it is not shown already.
+func (a *App) startTray() error {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	if a.tray != nil {
+		return nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+	tray := &trayIcon{app: a, conn: conn}
+
+	name := fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid())
+	if _, err := conn.RequestName(name, dbus.NameFlagDoNotQueue); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to acquire bus name: %w", err)
+	}
+
+	item := trayItem{tray}
+	menu := trayMenu{tray}
+	title := trayTitle(a.getUpdateCount())
+	tray.props, err = prop.Export(conn, sniPath, prop.Map{
+		sniInterface: {
+			"Category":   {Value: "SystemServices", Emit: prop.EmitFalse},
+			"Id":         {Value: "apm", Emit: prop.EmitFalse},
+			"Title":      {Value: title, Emit: prop.EmitFalse},
+			"Status":     {Value: "Active", Emit: prop.EmitFalse},
+			"IconName":   {Value: "system-software-update", Emit: prop.EmitFalse},
+			"ToolTip":    {Value: sniToolTip{IconName: "system-software-update", Icon: []sniPixmap{}, Title: title}, Emit: prop.EmitFalse},
+			"ItemIsMenu": {Value: false, Emit: prop.EmitFalse},
+			"Menu":       {Value: menuPath, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to export tray properties: %w", err)
+	}
+	menuProps, err := prop.Export(conn, menuPath, prop.Map{
+		menuInterface: {
+			"Version":       {Value: uint32(3), Emit: prop.EmitFalse},
+			"Status":        {Value: "normal", Emit: prop.EmitFalse},
+			"TextDirection": {Value: "ltr", Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to export menu properties: %w", err)
+	}
+
+	conn.Export(item, sniPath, sniInterface)
+	conn.Export(menu, menuPath, menuInterface)
+	conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name: string(sniPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: sniInterface, Methods: introspect.Methods(item), Properties: tray.props.Introspection(sniInterface)},
+		},
+	}), sniPath, "org.freedesktop.DBus.Introspectable")
+	conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name: string(menuPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: menuInterface, Methods: introspect.Methods(menu), Properties: menuProps.Introspection(menuInterface)},
+		},
+	}), menuPath, "org.freedesktop.DBus.Introspectable")
+
+	watcher := conn.Object("org.kde.StatusNotifierWatcher", "/StatusNotifierWatcher")
+	if call := watcher.Call("org.kde.StatusNotifierWatcher.RegisterStatusNotifierItem", 0, name); call.Err != nil {
+		conn.Close()
+		return fmt.Errorf("no system tray available: %w", call.Err)
+	}
+
+	a.tray = tray
+	return nil
+}
+
+// stopTray removes the tray icon.
+func (a *App) stopTray() {
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	if a.tray == nil {
+		return
+	}
+	a.tray.conn.Close()
+	a.tray = nil
+}
+
+// setUpdateCount records the number of available updates and shows it in the tray.
+func (a *App) setUpdateCount(count int) {
+	a.stateMu.Lock()
+	a.updateCount = count
+	a.stateMu.Unlock()
+
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	if a.tray == nil {
+		return
+	}
+	title := trayTitle(count)
+	a.tray.props.SetMust(sniInterface, "Title", title)
+	a.tray.props.SetMust(sniInterface, "ToolTip", sniToolTip{IconName: "system-software-update", Icon: []sniPixmap{}, Title: title})
+	status := "Active"
+	if count > 0 {
+		status = "NeedsAttention"
+	}
+	a.tray.props.SetMust(sniInterface, "Status", status)
+	a.tray.conn.Emit(sniPath, sniInterface+".NewTitle")
+	a.tray.conn.Emit(sniPath, sniInterface+".NewToolTip")
+	a.tray.conn.Emit(sniPath, sniInterface+".NewStatus", status)
+}
+
+func (a *App) getUpdateCount() int {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	return a.updateCount
+}
+
+func trayTitle(count int) string {
+	switch count {
+	case 0:
+		return "Arka Package Manager: system up to date"
+	case 1:
+		return "Arka Package Manager: 1 update available"
+	default:
+		return fmt.Sprintf("Arka Package Manager: %d updates available", count)
+	}
+}
+
+// showWindow brings the main window back from the tray.
+func (a *App) showWindow() {
+	runtime.WindowShow(a.ctx)
+	runtime.WindowUnminimise(a.ctx)
+}
+
+// quit exits the application, bypassing the minimize-to-tray behaviour.
+func (a *App) quit() {
+	a.stateMu.Lock()
+	a.quitting = true
+	a.stateMu.Unlock()
+	runtime.Quit(a.ctx)
+}
+
+func (i trayItem) Activate(x, y int32) *dbus.Error {
+	i.tray.app.showWindow()
+	return nil
+}
+
+func (i trayItem) SecondaryActivate(x, y int32) *dbus.Error {
+	i.tray.app.showWindow()
+	return nil
+}
+
+func (i trayItem) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+func (i trayItem) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+func (m trayMenu) GetLayout(parentID int32, recursionDepth int32, propertyNames []string) (uint32, menuLayout, *dbus.Error) {
+	item := func(id int32, label string) dbus.Variant {
+		return dbus.MakeVariant(menuLayout{
+			ID:         id,
+			Properties: map[string]dbus.Variant{"label": dbus.MakeVariant(label)},
+			Children:   []dbus.Variant{},
+		})
+	}
+	layout := menuLayout{
+		ID:         0,
+		Properties: map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")},
+		Children:   []dbus.Variant{item(menuItemOpen, "Open"), item(menuItemQuit, "Quit")},
+	}
+	return 1, layout, nil
+}
+
+func (m trayMenu) GetGroupProperties(ids []int32, propertyNames []string) ([]struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}, *dbus.Error) {
+	return nil, nil
+}
+
+func (m trayMenu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	return dbus.MakeVariant(""), nil
+}
+
+func (m trayMenu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID != "clicked" {
+		return nil
+	}
+	switch id {
+	case menuItemOpen:
+		m.tray.app.showWindow()
+	case menuItemQuit:
+		go m.tray.app.quit()
+	}
+	return nil
+}
+
+func (m trayMenu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+// SetAutostart adds or removes a desktop entry that starts the application
+// hidden in the tray when the user logs in.
+func (a *App) SetAutostart(enabled bool) error {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return fmt.Errorf("failed to get user config dir: %w", err)
+	}
+	path := filepath.Join(base, "autostart", autostartFile)
+
+	if !enabled {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove autostart entry: %w", err)
+		}
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	entry := strings.Join([]string{
+		"[Desktop Entry]",
+		"Type=Application",
+		"Name=Arka Package Manager",
+		"Comment=Check for package updates",
+		"Exec=" + desktopExecArg(exe) + " --hidden",
+		"Icon=system-software-update",
+		"Terminal=false",
+		"X-GNOME-Autostart-enabled=true",
+		"",
+	}, "\n")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create autostart dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(entry), 0o644); err != nil {
+		return fmt.Errorf("failed to write autostart entry: %w", err)
+	}
+	return nil
+}
+
+// desktopExecArg quotes an argument of the Exec key as the Desktop Entry
+// specification requires: arguments with reserved characters are double
+// quoted with ", `, $ and \ escaped, then backslashes are escaped again since
+// the key is a string value, and % is doubled as it starts field codes.
+func desktopExecArg(arg string) string {
+	quoted := arg
+	if strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		var b strings.Builder
+		b.WriteByte('"')
+		for _, r := range arg {
+			if strings.ContainsRune("\"`$\\", r) {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+		quoted = b.String()
+	}
+	quoted = strings.ReplaceAll(quoted, "\\", "\\\\")
+	return strings.ReplaceAll(quoted, "%", "%%")
+}
+
+// IsAutostartEnabled reports whether the autostart entry exists.
+func (a *App) IsAutostartEnabled() bool {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(base, "autostart", autostartFile))
+	return err == nil
+}
+
+// startedHidden reports whether the application was started with --hidden,
+// as done by the autostart entry.
+func startedHidden() bool {
+	for _, arg := range os.Args[1:] {
+		if arg == "--hidden" {
+			return true
+		}
+	}
+	return false
+}
+
+// closeToTray reports whether closing the window should only hide it.
+func (a *App) closeToTray() bool {
+	a.stateMu.Lock()
+	quitting := a.quitting
+	a.stateMu.Unlock()
+
+	trayMu.Lock()
+	defer trayMu.Unlock()
+	return a.tray != nil && !quitting
+}
+
+// applyTraySetting starts or stops the tray icon to match the settings. A
+// window started hidden always gets a tray icon so it can be brought back.
+func (a *App) applyTraySetting(settings Settings) {
+	if !settings.ResidentMode && !startedHidden() {
+		a.stopTray()
+		return
+	}
+	if err := a.startTray(); err != nil {
+		log.Printf("Error starting tray icon: %v", err)
+		// Without a tray icon a hidden window could not be brought back
+		runtime.WindowShow(a.ctx)
+	}
+}
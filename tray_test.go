@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestDesktopExecArg(t *testing.T) {
+	tests := []struct{ arg, want string }{
+		{`/usr/bin/apm`, `/usr/bin/apm`},
+		{`/opt/My Apps/apm`, `"/opt/My Apps/apm"`},
+		{`/home/user/100%/apm`, `/home/user/100%%/apm`},
+		// Quoted arguments escape ", `, $ and \, then the string value
+		// escapes every backslash again
+		{`/tmp/a"b`, `"/tmp/a\\"b"`},
+		{`/tmp/$HOME/apm`, `"/tmp/\\$HOME/apm"`},
+		{"/tmp/`id`", "\"/tmp/\\\\`id\\\\`\""},
+		{`/tmp/back\slash`, `"/tmp/back\\\\slash"`},
+		{`/tmp/it's 50%`, `"/tmp/it's 50%%"`},
+	}
+	for _, tt := range tests {
+		if got := desktopExecArg(tt.arg); got != tt.want {
+			t.Errorf("desktopExecArg(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
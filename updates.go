@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// updateCheckInterval is how long CheckUpdates reuses the result of the last
+// check before synchronizing the databases again.
+const updateCheckInterval = 10 * time.Minute
+
+// Kinds of entries returned by GetAvailableUpdates.
+const (
+	updateKindUpgrade  = "upgrade"
+	updateKindReplace  = "replace"
+	updateKindConflict = "conflict"
+)
+
+// findReplacements returns the sync packages that replace installed packages,
+// as declared by their replaces array. Replacements that are already
+// installed are skipped.
+func findReplacements(localDB alpm.IDB, syncDBs alpm.IDBList, filter updateFilter, cacheDirs []string) []UpdateInfo {
+	installed := localDB.PkgCache().Slice()
+
+	var replacements []UpdateInfo
+	seen := make(map[string]bool)
+	syncDBs.ForEach(func(db alpm.IDB) error {
+		return db.PkgCache().ForEach(func(newPkg alpm.IPackage) error {
+			if seen[newPkg.Name()] || localDB.Pkg(newPkg.Name()) != nil {
+				return nil
+			}
+			for _, replace := range newPkg.Replaces().Slice() {
+				for _, pkg := range installed {
+					// Only a package of the same name is replaced, not its providers.
+					if pkg.Name() != replace.Name || !versionSatisfies(pkg.Version(), replace) {
+						continue
+					}
+					seen[newPkg.Name()] = true
+					replacements = append(replacements, UpdateInfo{
+						Name:          newPkg.Name(),
+						OldVersion:    pkg.Version(),
+						NewVersion:    newPkg.Version(),
+						Repository:    db.Name(),
+						DownloadSize:  downloadSize(newPkg, cacheDirs),
+						InstalledSize: newPkg.ISize(),
+						SizeChange:    newPkg.ISize() - pkg.ISize(),
+						Kind:          updateKindReplace,
+						Replaces:      []string{pkg.Name()},
+					})
+				}
+			}
+			return nil
+		})
+	})
+
+	// A package may replace several installed packages; merge those entries.
+	merged := replacements[:0]
+	index := make(map[string]int)
+	for _, r := range replacements {
+		if i, ok := index[r.Name]; ok {
+			merged[i].Replaces = append(merged[i].Replaces, r.Replaces...)
+			// The new package is only installed once, so every further
+			// replaced package only frees its installed size.
+			merged[i].SizeChange -= r.InstalledSize - r.SizeChange
+			continue
+		}
+		index[r.Name] = len(merged)
+		merged = append(merged, r)
+	}
+
+	for i := range merged {
+		newPkg := syncPkg(syncDBs, merged[i].Repository, merged[i].Name)
+		if newPkg == nil {
+			continue
+		}
+		merged[i].Conflicts = findConflicts(localDB, newPkg, merged[i].Replaces...)
+		filter.apply(&merged[i], newPkg.Groups().Slice())
+	}
+	return merged
+}
+
+// syncPkg looks up a package by name in the named sync database.
+func syncPkg(syncDBs alpm.IDBList, repo, name string) alpm.IPackage {
+	var pkg alpm.IPackage
+	syncDBs.ForEach(func(db alpm.IDB) error {
+		if db.Name() == repo {
+			pkg = db.Pkg(name)
+		}
+		return nil
+	})
+	return pkg
+}
+
+// findConflicts returns the installed packages that newPkg conflicts with.
+// Packages named in skip, such as the ones being upgraded or replaced, are
+// not reported.
+func findConflicts(localDB alpm.IDB, newPkg alpm.IPackage, skip ...string) []string {
+	var conflicts []string
+	for _, conflict := range newPkg.Conflicts().Slice() {
+		localDB.PkgCache().ForEach(func(pkg alpm.IPackage) error {
+			if pkg.Name() == newPkg.Name() || contains(skip, pkg.Name()) || contains(conflicts, pkg.Name()) {
+				return nil
+			}
+			if satisfies(pkg, conflict) {
+				conflicts = append(conflicts, pkg.Name())
+			}
+			return nil
+		})
+	}
+	return conflicts
+}
+
+func contains(slice []string, item string) bool {
+	return indexOf(slice, item) >= 0
+}
+
+// UpdateSummary aggregates a list of updates. Ignored updates only count
+// towards Ignored and updates of unknown size towards UnknownSize.
+type UpdateSummary struct {
+	Count           int                     `json:"count"`
+	Ignored         int                     `json:"ignored"`
+	UnknownSize     int                     `json:"unknownSize"`
+	TotalDownload   int64                   `json:"totalDownload"`
+	TotalSizeChange int64                   `json:"totalSizeChange"`
+	ByRepository    map[string]*RepoSummary `json:"byRepository"`
+}
+
+// RepoSummary aggregates the updates of a single repository.
+type RepoSummary struct {
+	Count      int   `json:"count"`
+	Download   int64 `json:"download"`
+	SizeChange int64 `json:"sizeChange"`
+}
+
+// UpdateReport is the result of CheckUpdates. News holds the unread Arch
+// news published since the last upgrade, which should be acknowledged before
+// upgrading.
+type UpdateReport struct {
+	Updates []UpdateInfo  `json:"updates"`
+	Summary UpdateSummary `json:"summary"`
+	News    []NewsItem    `json:"news"`
+}
+
+func summarizeUpdates(updates []UpdateInfo) UpdateSummary {
+	summary := UpdateSummary{ByRepository: make(map[string]*RepoSummary)}
+	for _, update := range updates {
+		if update.Ignored {
+			summary.Ignored++
+			continue
+		}
+		summary.Count++
+		repo, ok := summary.ByRepository[update.Repository]
+		if !ok {
+			repo = &RepoSummary{}
+			summary.ByRepository[update.Repository] = repo
+		}
+		repo.Count++
+		if update.SizeUnknown {
+			summary.UnknownSize++
+			continue
+		}
+		summary.TotalDownload += update.DownloadSize
+		summary.TotalSizeChange += update.SizeChange
+		repo.Download += update.DownloadSize
+		repo.SizeChange += update.SizeChange
+	}
+	return summary
+}
+
+// downloadSize returns the number of bytes that have to be downloaded for
+// pkg, which is zero when the package file already is in one of the cache
+// directories.
+func downloadSize(pkg alpm.IPackage, cacheDirs []string) int64 {
+	for _, dir := range cacheDirs {
+		fi, err := os.Stat(filepath.Join(dir, pkg.FileName()))
+		if err == nil && fi.Size() == pkg.Size() {
+			return 0
+		}
+	}
+	return pkg.Size()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+func TestFindReplacements(t *testing.T) {
+	local := newFakeDB("local",
+		&fakePkg{name: "oldname", version: "1.0-1", isize: 200},
+		&fakePkg{name: "python2-foo", version: "1.0-1", isize: 40},
+		&fakePkg{name: "python2-bar", version: "1.1-1", isize: 30},
+		&fakePkg{name: "libfoo", version: "1.0-1"},
+		&fakePkg{name: "recent", version: "2.0-1"},
+		&fakePkg{name: "virtual-provider", version: "1.0-1", provides: []alpm.Depend{{Name: "virtual"}}},
+	)
+	syncDBs := fakeDBList{
+		newFakeDB("core",
+			// Installed packages are not offered as replacements
+			&fakePkg{name: "libfoo", version: "1.0-1", replaces: []alpm.Depend{{Name: "oldname"}}},
+		),
+		newFakeDB("extra",
+			&fakePkg{name: "newname", version: "2.0-1", size: 100, isize: 300,
+				replaces:  []alpm.Depend{{Name: "oldname"}},
+				conflicts: []alpm.Depend{{Name: "libfoo"}, {Name: "oldname"}}},
+			&fakePkg{name: "python-foo", version: "2.0-1", size: 50, isize: 80,
+				replaces: []alpm.Depend{{Name: "python2-foo"}, {Name: "python2-bar"}}},
+			&fakePkg{name: "old-versions-only", version: "3.0-1",
+				replaces: []alpm.Depend{{Name: "recent", Version: "2.0", Mod: alpm.DepModLT}}},
+			&fakePkg{name: "virtual-ng", version: "1.0-1",
+				replaces: []alpm.Depend{{Name: "virtual"}}},
+		),
+	}
+
+	filter := updateFilter{ignorePkg: []string{"python-*"}}
+	got := findReplacements(local, syncDBs, filter, nil)
+	want := []UpdateInfo{
+		{
+			Name:          "newname",
+			OldVersion:    "1.0-1",
+			NewVersion:    "2.0-1",
+			Repository:    "extra",
+			DownloadSize:  100,
+			InstalledSize: 300,
+			SizeChange:    100,
+			Kind:          updateKindReplace,
+			Replaces:      []string{"oldname"},
+			Conflicts:     []string{"libfoo"},
+		},
+		{
+			Name:          "python-foo",
+			OldVersion:    "1.0-1",
+			NewVersion:    "2.0-1",
+			Repository:    "extra",
+			DownloadSize:  50,
+			InstalledSize: 80,
+			// the second replaced package only frees its installed size
+			SizeChange:   10,
+			Ignored:      true,
+			IgnoreReason: "IgnorePkg",
+			Kind:         updateKindReplace,
+			Replaces:     []string{"python2-foo", "python2-bar"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findReplacements() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	local := newFakeDB("local",
+		&fakePkg{name: "foo", version: "1.0-1"},
+		&fakePkg{name: "bar", version: "1.0-1", provides: []alpm.Depend{{Name: "libbar", Version: "1", Mod: alpm.DepModEq}}},
+		&fakePkg{name: "baz", version: "3.0-1"},
+	)
+	newPkg := &fakePkg{name: "foo", version: "2.0-1", conflicts: []alpm.Depend{
+		{Name: "foo"},
+		{Name: "libbar", Version: "2", Mod: alpm.DepModLT},
+		{Name: "baz", Version: "3.0", Mod: alpm.DepModLT},
+	}}
+
+	if got := findConflicts(local, newPkg); !reflect.DeepEqual(got, []string{"bar"}) {
+		t.Errorf("findConflicts() = %v, want [bar]", got)
+	}
+	if got := findConflicts(local, newPkg, "bar"); got != nil {
+		t.Errorf("findConflicts() skipping bar = %v, want none", got)
+	}
+}
+
+func TestSummarizeUpdates(t *testing.T) {
+	summary := summarizeUpdates([]UpdateInfo{
+		{Name: "linux", Repository: "core", DownloadSize: 150, SizeChange: 10},
+		{Name: "glibc", Repository: "core", DownloadSize: 10, SizeChange: -2},
+		{Name: "firefox", Repository: "extra", DownloadSize: 70, SizeChange: 5},
+		{Name: "nvidia", Repository: "extra", DownloadSize: 300, SizeChange: 40, Ignored: true},
+		{Name: "yay", Repository: "aur", SizeUnknown: true},
+	})
+
+	if summary.Count != 4 || summary.Ignored != 1 || summary.UnknownSize != 1 {
+		t.Errorf("count = %d, ignored = %d, unknown size = %d; want 4, 1, 1",
+			summary.Count, summary.Ignored, summary.UnknownSize)
+	}
+	if summary.TotalDownload != 230 || summary.TotalSizeChange != 13 {
+		t.Errorf("total download = %d, size change = %d; want 230, 13", summary.TotalDownload, summary.TotalSizeChange)
+	}
+	want := map[string]RepoSummary{
+		"core":  {Count: 2, Download: 160, SizeChange: 8},
+		"extra": {Count: 1, Download: 70, SizeChange: 5},
+		"aur":   {Count: 1},
+	}
+	if len(summary.ByRepository) != len(want) {
+		t.Errorf("repositories = %v, want %v", summary.ByRepository, want)
+	}
+	for repo, w := range want {
+		if got := summary.ByRepository[repo]; got == nil || *got != w {
+			t.Errorf("repository %s = %+v, want %+v", repo, got, w)
+		}
+	}
+}
+
+func TestDownloadSize(t *testing.T) {
+	pkg := &fakePkg{name: "linux", version: "6.2-1", size: 4}
+	emptyDir, cacheDir := t.TempDir(), t.TempDir()
+
+	if got := downloadSize(pkg, []string{emptyDir, cacheDir}); got != 4 {
+		t.Errorf("uncached package: download size = %d, want 4", got)
+	}
+
+	// A partial download still has to be completed
+	path := filepath.Join(cacheDir, pkg.FileName())
+	if err := os.WriteFile(path, []byte("ab"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := downloadSize(pkg, []string{emptyDir, cacheDir}); got != 4 {
+		t.Errorf("partially cached package: download size = %d, want 4", got)
+	}
+
+	if err := os.WriteFile(path, []byte("abcd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := downloadSize(pkg, []string{emptyDir, cacheDir}); got != 0 {
+		t.Errorf("cached package: download size = %d, want 0", got)
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// VerifyIssue is a difference between an installed file and the package.
+type VerifyIssue struct {
+	Path string `json:"path"`
+	// Kind is missing, type, mode, owner, size, checksum or link.
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	// Backup is set for configuration files, which are expected to change.
+	Backup bool `json:"backup,omitempty"`
+}
+
+// VerifyReport is the result of verifying one package.
+type VerifyReport struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	Checked int    `json:"checked"`
+	// Skipped counts files that could not be read, usually because they
+	// are only readable by root.
+	Skipped int           `json:"skipped"`
+	Issues  []VerifyIssue `json:"issues"`
+	// Error is set when the package has no usable mtree data.
+	Error string `json:"error,omitempty"`
+}
+
+// SystemVerifyReport is the result of VerifySystem. Reports only lists the
+// packages with issues or errors.
+type SystemVerifyReport struct {
+	Packages int            `json:"packages"`
+	Checked  int            `json:"checked"`
+	Skipped  int            `json:"skipped"`
+	Reports  []VerifyReport `json:"reports"`
+}
+
+// VerifyProgress is emitted as a "verify:progress" event during VerifySystem.
+type VerifyProgress struct {
+	Package string `json:"package"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+}
+
+// verifyTarget is what verification needs from the local database, copied
+// out so that files can be checked concurrently without the ALPM handle.
+type verifyTarget struct {
+	name    string
+	version string
+	backup  map[string]bool
+}
+
+// mtreeEntry is a file of a package mtree.
+type mtreeEntry struct {
+	path   string
+	kind   string
+	uid    int
+	gid    int
+	mode   uint32
+	size   int64
+	sha256 string
+	link   string
+}
+
+// VerifyPackage checks the installed files of a package against the mtree
+// data in the local database, like pacman -Qkk.
+func (a *App) VerifyPackage(name string) (*VerifyReport, error) {
+	targets, err := verifyTargets(name)
+	if err != nil {
+		return nil, err
+	}
+	report := verifyTargetFiles(targets[0], true)
+	return &report, nil
+}
+
+// VerifySystem checks the files of all installed packages against their
+// mtree metadata: presence, type, link target, mode, owner and size, as
+// pacman -Qkk does. With checksums set the SHA-256 sums are compared too,
+// which reads every installed file. Progress is emitted as "verify:progress"
+// events.
+func (a *App) VerifySystem(checksums bool) (*SystemVerifyReport, error) {
+	targets, err := verifyTargets("")
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan verifyTarget)
+	results := make(chan VerifyReport)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				results <- verifyTargetFiles(target, checksums)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, target := range targets {
+			select {
+			case jobs <- target:
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	summary := &SystemVerifyReport{}
+	for report := range results {
+		summary.Packages++
+		summary.Checked += report.Checked
+		summary.Skipped += report.Skipped
+		if len(report.Issues) > 0 || report.Error != "" {
+			summary.Reports = append(summary.Reports, report)
+		}
+		a.emit("verify:progress", VerifyProgress{Package: report.Package, Done: summary.Packages, Total: len(targets)})
+	}
+	if err := a.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(summary.Reports, func(i, j int) bool { return summary.Reports[i].Package < summary.Reports[j].Package })
+	return summary, nil
+}
+
+// verifyTargets reads the package to verify, or all installed packages when
+// name is empty.
+func verifyTargets(name string) ([]verifyTarget, error) {
+	h, err := alpm.Initialize("/", pacmanDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize alpm: %v", err)
+	}
+	defer h.Release()
+
+	localDB, err := h.LocalDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get local DB: %v", err)
+	}
+
+	var packages []alpm.IPackage
+	if name != "" {
+		pkg := localDB.Pkg(name)
+		if pkg == nil {
+			return nil, fmt.Errorf("package %s is not installed", name)
+		}
+		packages = append(packages, pkg)
+	} else {
+		packages = localDB.PkgCache().Slice()
+	}
+
+	targets := make([]verifyTarget, 0, len(packages))
+	for _, pkg := range packages {
+		target := verifyTarget{name: pkg.Name(), version: pkg.Version(), backup: make(map[string]bool)}
+		pkg.Backup().ForEach(func(file alpm.BackupFile) error {
+			target.backup[file.Name] = true
+			return nil
+		})
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func verifyTargetFiles(target verifyTarget, checksums bool) VerifyReport {
+	report := VerifyReport{Package: target.name, Version: target.version}
+	entries, err := readMtree(filepath.Join(pacmanDBPath, "local", target.name+"-"+target.version, "mtree"))
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	for _, entry := range entries {
+		report.Checked++
+		issues, skipped := verifyEntry(entry, checksums)
+		if skipped {
+			report.Skipped++
+		}
+		for _, issue := range issues {
+			issue.Backup = target.backup[strings.TrimPrefix(entry.path, "/")]
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	return report
+}
+
+// verifyEntry compares a file with its mtree entry. skipped is set when the
+// file or its contents could not be read.
+func verifyEntry(entry mtreeEntry, checksums bool) (issues []VerifyIssue, skipped bool) {
+	issue := func(kind, expected, actual string) {
+		issues = append(issues, VerifyIssue{Path: entry.path, Kind: kind, Expected: expected, Actual: actual})
+	}
+
+	fi, err := os.Lstat(entry.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		issue("missing", "", "")
+		return issues, false
+	}
+	if err != nil {
+		// e.g. a parent directory only root can search
+		return issues, true
+	}
+
+	kind := "file"
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		kind = "link"
+	case fi.IsDir():
+		kind = "dir"
+	}
+	if kind != entry.kind {
+		issue("type", entry.kind, kind)
+		return issues, false
+	}
+
+	if kind == "link" {
+		target, err := os.Readlink(entry.path)
+		if err == nil && target != entry.link {
+			issue("link", entry.link, target)
+		}
+		return issues, false
+	}
+
+	if mode := unixMode(fi.Mode()); mode != entry.mode {
+		issue("mode", fmt.Sprintf("%04o", entry.mode), fmt.Sprintf("%04o", mode))
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && (int(st.Uid) != entry.uid || int(st.Gid) != entry.gid) {
+		issue("owner", fmt.Sprintf("%d:%d", entry.uid, entry.gid), fmt.Sprintf("%d:%d", st.Uid, st.Gid))
+	}
+	if kind == "dir" {
+		return issues, false
+	}
+
+	if fi.Size() != entry.size {
+		issue("size", strconv.FormatInt(entry.size, 10), strconv.FormatInt(fi.Size(), 10))
+		return issues, false
+	}
+	if !checksums || entry.sha256 == "" {
+		return issues, false
+	}
+	sum, err := fileSHA256(entry.path)
+	if err != nil {
+		return issues, true
+	}
+	if sum != entry.sha256 {
+		issue("checksum", entry.sha256, sum)
+	}
+	return issues, false
+}
+
+// unixMode returns the permission bits of mode including setuid, setgid and
+// sticky, as written in mtree files.
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readMtree parses the gzipped mtree file of a package. The package metadata
+// files such as .PKGINFO are left out since they are not installed.
+func readMtree(path string) ([]mtreeEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("no mtree data: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mtree data: %w", err)
+	}
+	defer gz.Close()
+
+	defaults := make(map[string]string)
+	var entries []mtreeEntry
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "/set":
+			for _, field := range fields[1:] {
+				if key, value, ok := strings.Cut(field, "="); ok {
+					defaults[key] = value
+				}
+			}
+			continue
+		case "/unset":
+			for _, key := range fields[1:] {
+				delete(defaults, key)
+			}
+			continue
+		}
+
+		name := mtreeUnescape(fields[0])
+		if strings.HasPrefix(name, "./.") {
+			continue
+		}
+		keywords := make(map[string]string, len(defaults)+len(fields))
+		for key, value := range defaults {
+			keywords[key] = value
+		}
+		for _, field := range fields[1:] {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				keywords[key] = value
+			}
+		}
+
+		entry := mtreeEntry{
+			path:   "/" + strings.TrimPrefix(name, "./"),
+			kind:   keywords["type"],
+			sha256: keywords["sha256digest"],
+			link:   mtreeUnescape(keywords["link"]),
+		}
+		entry.uid, _ = strconv.Atoi(keywords["uid"])
+		entry.gid, _ = strconv.Atoi(keywords["gid"])
+		mode, _ := strconv.ParseUint(keywords["mode"], 8, 32)
+		entry.mode = uint32(mode)
+		entry.size, _ = strconv.ParseInt(keywords["size"], 10, 64)
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid mtree data: %w", err)
+	}
+	return entries, nil
+}
+
+// mtreeUnescape decodes the \ooo octal escapes mtree uses for special
+// characters in paths.
+func mtreeUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
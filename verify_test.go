@@ -0,0 +1,114 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMtreeUnescape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"./usr/bin/vim", "./usr/bin/vim"},
+		{`./usr/share/My\040Docs`, "./usr/share/My Docs"},
+		{`./a\134b`, `./a\b`},
+		{`./caf\303\251`, "./café"},
+		{`./trailing\04`, `./trailing\04`},
+		{`./not\9octal`, `./not\9octal`},
+		{`./end\`, `./end\`},
+	}
+	for _, tt := range tests {
+		if got := mtreeUnescape(tt.in); got != tt.want {
+			t.Errorf("mtreeUnescape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadMtree(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []mtreeEntry
+		wantErr bool
+	}{
+		{
+			name: "defaults and overrides",
+			data: `#mtree
+/set type=file uid=0 gid=0 mode=644
+./.BUILDINFO time=1700000000.0 size=100 sha256digest=aa
+./.PKGINFO time=1700000000.0 size=200 sha256digest=bb
+./usr time=1700000000.0 mode=755 type=dir
+./usr/bin/tool time=1700000000.0 mode=4755 size=1024 sha256digest=cc
+./usr/lib/libfoo.so time=1700000000.0 type=link link=libfoo.so.1
+`,
+			want: []mtreeEntry{
+				{path: "/usr", kind: "dir", mode: 0o755},
+				{path: "/usr/bin/tool", kind: "file", mode: 0o4755, size: 1024, sha256: "cc"},
+				{path: "/usr/lib/libfoo.so", kind: "link", mode: 0o644, link: "libfoo.so.1"},
+			},
+		},
+		{
+			name: "unset and escapes",
+			data: `/set type=file uid=0 gid=0 mode=644
+/unset mode
+./etc/My\040App/conf\040file uid=33 gid=33 size=5
+./usr/lib/link\040name type=link link=target\040name
+`,
+			want: []mtreeEntry{
+				{path: "/etc/My App/conf file", kind: "file", uid: 33, gid: 33, size: 5},
+				{path: "/usr/lib/link name", kind: "link", link: "target name"},
+			},
+		},
+		{
+			name: "empty",
+			data: "#mtree\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mtree")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gz := gzip.NewWriter(f)
+			if _, err := gz.Write([]byte(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readMtree(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readMtree() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readMtree() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMtreeInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := readMtree(filepath.Join(dir, "missing")); err == nil {
+		t.Error("readMtree() of a missing file succeeded")
+	}
+
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, []byte("./usr type=dir\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMtree(plain); err == nil {
+		t.Error("readMtree() of uncompressed data succeeded")
+	}
+}